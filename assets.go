@@ -775,3 +775,264 @@ func logo() []byte {
 		0x44, 0xae, 0x42, 0x60, 0x82,
 	}
 }
+
+func embedJS() []byte {
+	return []byte{
+		0x2f, 0x2f, 0x20, 0x65, 0x6d, 0x62, 0x65, 0x64, 0x2e, 0x6a, 0x73, 0x20,
+		0x69, 0x73, 0x20, 0x69, 0x6e, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x64, 0x20,
+		0x69, 0x6e, 0x20, 0x6f, 0x45, 0x6d, 0x62, 0x65, 0x64, 0x20, 0x48, 0x54,
+		0x4d, 0x4c, 0x2e, 0x20, 0x49, 0x74, 0x20, 0x6c, 0x69, 0x73, 0x74, 0x65,
+		0x6e, 0x73, 0x20, 0x66, 0x6f, 0x72, 0x20, 0x74, 0x68, 0x65, 0x20, 0x68,
+		0x65, 0x69, 0x67, 0x68, 0x74, 0x20, 0x72, 0x65, 0x70, 0x6f, 0x72, 0x74,
+		0x65, 0x64, 0x20, 0x62, 0x79, 0x0a, 0x2f, 0x2f, 0x20, 0x72, 0x65, 0x73,
+		0x69, 0x7a, 0x65, 0x2e, 0x6a, 0x73, 0x20, 0x69, 0x6e, 0x20, 0x61, 0x20,
+		0x68, 0x6f, 0x73, 0x74, 0x65, 0x64, 0x20, 0x70, 0x61, 0x67, 0x65, 0x20,
+		0x61, 0x6e, 0x64, 0x20, 0x72, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x73, 0x20,
+		0x74, 0x68, 0x65, 0x20, 0x6d, 0x61, 0x74, 0x63, 0x68, 0x69, 0x6e, 0x67,
+		0x20, 0x65, 0x6d, 0x62, 0x65, 0x64, 0x20, 0x74, 0x6f, 0x20, 0x66, 0x69,
+		0x74, 0x2e, 0x0a, 0x28, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+		0x28, 0x29, 0x20, 0x7b, 0x0a, 0x09, 0x69, 0x66, 0x20, 0x28, 0x77, 0x69,
+		0x6e, 0x64, 0x6f, 0x77, 0x2e, 0x67, 0x69, 0x73, 0x74, 0x45, 0x78, 0x70,
+		0x6f, 0x73, 0x65, 0x64, 0x45, 0x6d, 0x62, 0x65, 0x64, 0x29, 0x20, 0x72,
+		0x65, 0x74, 0x75, 0x72, 0x6e, 0x3b, 0x0a, 0x09, 0x77, 0x69, 0x6e, 0x64,
+		0x6f, 0x77, 0x2e, 0x67, 0x69, 0x73, 0x74, 0x45, 0x78, 0x70, 0x6f, 0x73,
+		0x65, 0x64, 0x45, 0x6d, 0x62, 0x65, 0x64, 0x20, 0x3d, 0x20, 0x74, 0x72,
+		0x75, 0x65, 0x3b, 0x0a, 0x0a, 0x09, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77,
+		0x2e, 0x61, 0x64, 0x64, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x4c, 0x69, 0x73,
+		0x74, 0x65, 0x6e, 0x65, 0x72, 0x28, 0x22, 0x6d, 0x65, 0x73, 0x73, 0x61,
+		0x67, 0x65, 0x22, 0x2c, 0x20, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f,
+		0x6e, 0x28, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x29, 0x20, 0x7b, 0x0a, 0x09,
+		0x09, 0x76, 0x61, 0x72, 0x20, 0x64, 0x61, 0x74, 0x61, 0x20, 0x3d, 0x20,
+		0x65, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x64, 0x61, 0x74, 0x61, 0x3b, 0x0a,
+		0x09, 0x09, 0x69, 0x66, 0x20, 0x28, 0x21, 0x64, 0x61, 0x74, 0x61, 0x20,
+		0x7c, 0x7c, 0x20, 0x64, 0x61, 0x74, 0x61, 0x2e, 0x74, 0x79, 0x70, 0x65,
+		0x20, 0x21, 0x3d, 0x3d, 0x20, 0x22, 0x67, 0x69, 0x73, 0x74, 0x2d, 0x65,
+		0x78, 0x70, 0x6f, 0x73, 0x65, 0x64, 0x3a, 0x72, 0x65, 0x73, 0x69, 0x7a,
+		0x65, 0x22, 0x20, 0x7c, 0x7c, 0x20, 0x74, 0x79, 0x70, 0x65, 0x6f, 0x66,
+		0x20, 0x64, 0x61, 0x74, 0x61, 0x2e, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74,
+		0x20, 0x21, 0x3d, 0x3d, 0x20, 0x22, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72,
+		0x22, 0x29, 0x20, 0x72, 0x65, 0x74, 0x75, 0x72, 0x6e, 0x3b, 0x0a, 0x0a,
+		0x09, 0x09, 0x76, 0x61, 0x72, 0x20, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x73,
+		0x20, 0x3d, 0x20, 0x64, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x2e,
+		0x71, 0x75, 0x65, 0x72, 0x79, 0x53, 0x65, 0x6c, 0x65, 0x63, 0x74, 0x6f,
+		0x72, 0x41, 0x6c, 0x6c, 0x28, 0x22, 0x2e, 0x67, 0x69, 0x73, 0x74, 0x2d,
+		0x65, 0x78, 0x70, 0x6f, 0x73, 0x65, 0x64, 0x20, 0x69, 0x66, 0x72, 0x61,
+		0x6d, 0x65, 0x22, 0x29, 0x3b, 0x0a, 0x09, 0x09, 0x66, 0x6f, 0x72, 0x20,
+		0x28, 0x76, 0x61, 0x72, 0x20, 0x69, 0x20, 0x3d, 0x20, 0x30, 0x3b, 0x20,
+		0x69, 0x20, 0x3c, 0x20, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x73, 0x2e, 0x6c,
+		0x65, 0x6e, 0x67, 0x74, 0x68, 0x3b, 0x20, 0x69, 0x2b, 0x2b, 0x29, 0x20,
+		0x7b, 0x0a, 0x09, 0x09, 0x09, 0x69, 0x66, 0x20, 0x28, 0x66, 0x72, 0x61,
+		0x6d, 0x65, 0x73, 0x5b, 0x69, 0x5d, 0x2e, 0x63, 0x6f, 0x6e, 0x74, 0x65,
+		0x6e, 0x74, 0x57, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x20, 0x21, 0x3d, 0x3d,
+		0x20, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x73, 0x6f, 0x75, 0x72, 0x63,
+		0x65, 0x29, 0x20, 0x63, 0x6f, 0x6e, 0x74, 0x69, 0x6e, 0x75, 0x65, 0x3b,
+		0x0a, 0x0a, 0x09, 0x09, 0x09, 0x2f, 0x2f, 0x20, 0x4e, 0x65, 0x76, 0x65,
+		0x72, 0x20, 0x67, 0x72, 0x6f, 0x77, 0x20, 0x70, 0x61, 0x73, 0x74, 0x20,
+		0x74, 0x68, 0x65, 0x20, 0x63, 0x6f, 0x6e, 0x73, 0x75, 0x6d, 0x65, 0x72,
+		0x27, 0x73, 0x20, 0x6d, 0x61, 0x78, 0x69, 0x6d, 0x75, 0x6d, 0x20, 0x68,
+		0x65, 0x69, 0x67, 0x68, 0x74, 0x2c, 0x20, 0x69, 0x66, 0x20, 0x74, 0x68,
+		0x65, 0x72, 0x65, 0x20, 0x69, 0x73, 0x20, 0x6f, 0x6e, 0x65, 0x2e, 0x0a,
+		0x09, 0x09, 0x09, 0x76, 0x61, 0x72, 0x20, 0x64, 0x69, 0x76, 0x20, 0x3d,
+		0x20, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x73, 0x5b, 0x69, 0x5d, 0x2e, 0x70,
+		0x61, 0x72, 0x65, 0x6e, 0x74, 0x4e, 0x6f, 0x64, 0x65, 0x3b, 0x0a, 0x09,
+		0x09, 0x09, 0x76, 0x61, 0x72, 0x20, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74,
+		0x20, 0x3d, 0x20, 0x4d, 0x61, 0x74, 0x68, 0x2e, 0x6d, 0x61, 0x78, 0x28,
+		0x30, 0x2c, 0x20, 0x4d, 0x61, 0x74, 0x68, 0x2e, 0x66, 0x6c, 0x6f, 0x6f,
+		0x72, 0x28, 0x64, 0x61, 0x74, 0x61, 0x2e, 0x68, 0x65, 0x69, 0x67, 0x68,
+		0x74, 0x29, 0x29, 0x3b, 0x0a, 0x09, 0x09, 0x09, 0x76, 0x61, 0x72, 0x20,
+		0x6d, 0x61, 0x78, 0x20, 0x3d, 0x20, 0x70, 0x61, 0x72, 0x73, 0x65, 0x49,
+		0x6e, 0x74, 0x28, 0x64, 0x69, 0x76, 0x2e, 0x67, 0x65, 0x74, 0x41, 0x74,
+		0x74, 0x72, 0x69, 0x62, 0x75, 0x74, 0x65, 0x28, 0x22, 0x64, 0x61, 0x74,
+		0x61, 0x2d, 0x6d, 0x61, 0x78, 0x2d, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74,
+		0x22, 0x29, 0x2c, 0x20, 0x31, 0x30, 0x29, 0x3b, 0x0a, 0x09, 0x09, 0x09,
+		0x69, 0x66, 0x20, 0x28, 0x6d, 0x61, 0x78, 0x20, 0x3e, 0x20, 0x30, 0x20,
+		0x26, 0x26, 0x20, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x20, 0x3e, 0x20,
+		0x6d, 0x61, 0x78, 0x29, 0x20, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x20,
+		0x3d, 0x20, 0x6d, 0x61, 0x78, 0x3b, 0x0a, 0x09, 0x09, 0x09, 0x64, 0x69,
+		0x76, 0x2e, 0x73, 0x74, 0x79, 0x6c, 0x65, 0x2e, 0x70, 0x61, 0x64, 0x64,
+		0x69, 0x6e, 0x67, 0x42, 0x6f, 0x74, 0x74, 0x6f, 0x6d, 0x20, 0x3d, 0x20,
+		0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x20, 0x2b, 0x20, 0x22, 0x70, 0x78,
+		0x22, 0x3b, 0x0a, 0x09, 0x09, 0x7d, 0x0a, 0x09, 0x7d, 0x29, 0x3b, 0x0a,
+		0x7d, 0x29, 0x28, 0x29, 0x3b, 0x0a,
+	}
+}
+
+func resizeJS() []byte {
+	return []byte{
+		0x2f, 0x2f, 0x20, 0x72, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x2e, 0x6a, 0x73,
+		0x20, 0x69, 0x73, 0x20, 0x61, 0x64, 0x64, 0x65, 0x64, 0x20, 0x74, 0x6f,
+		0x20, 0x68, 0x6f, 0x73, 0x74, 0x65, 0x64, 0x20, 0x48, 0x54, 0x4d, 0x4c,
+		0x20, 0x70, 0x61, 0x67, 0x65, 0x73, 0x2e, 0x20, 0x49, 0x74, 0x20, 0x72,
+		0x65, 0x70, 0x6f, 0x72, 0x74, 0x73, 0x20, 0x74, 0x68, 0x65, 0x20, 0x68,
+		0x65, 0x69, 0x67, 0x68, 0x74, 0x20, 0x6f, 0x66, 0x20, 0x74, 0x68, 0x65,
+		0x20, 0x70, 0x61, 0x67, 0x65, 0x0a, 0x2f, 0x2f, 0x20, 0x74, 0x6f, 0x20,
+		0x74, 0x68, 0x65, 0x20, 0x65, 0x6d, 0x62, 0x65, 0x64, 0x64, 0x69, 0x6e,
+		0x67, 0x20, 0x70, 0x61, 0x67, 0x65, 0x20, 0x73, 0x6f, 0x20, 0x74, 0x68,
+		0x61, 0x74, 0x20, 0x65, 0x6d, 0x62, 0x65, 0x64, 0x2e, 0x6a, 0x73, 0x20,
+		0x63, 0x61, 0x6e, 0x20, 0x72, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x20, 0x74,
+		0x68, 0x65, 0x20, 0x69, 0x66, 0x72, 0x61, 0x6d, 0x65, 0x20, 0x74, 0x6f,
+		0x20, 0x66, 0x69, 0x74, 0x2e, 0x0a, 0x28, 0x66, 0x75, 0x6e, 0x63, 0x74,
+		0x69, 0x6f, 0x6e, 0x28, 0x29, 0x20, 0x7b, 0x0a, 0x09, 0x69, 0x66, 0x20,
+		0x28, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x2e, 0x70, 0x61, 0x72, 0x65,
+		0x6e, 0x74, 0x20, 0x3d, 0x3d, 0x3d, 0x20, 0x77, 0x69, 0x6e, 0x64, 0x6f,
+		0x77, 0x29, 0x20, 0x72, 0x65, 0x74, 0x75, 0x72, 0x6e, 0x3b, 0x0a, 0x0a,
+		0x09, 0x76, 0x61, 0x72, 0x20, 0x6c, 0x61, 0x73, 0x74, 0x20, 0x3d, 0x20,
+		0x2d, 0x31, 0x3b, 0x0a, 0x09, 0x66, 0x75, 0x6e, 0x63, 0x74, 0x69, 0x6f,
+		0x6e, 0x20, 0x70, 0x6f, 0x73, 0x74, 0x28, 0x29, 0x20, 0x7b, 0x0a, 0x09,
+		0x09, 0x76, 0x61, 0x72, 0x20, 0x65, 0x6c, 0x20, 0x3d, 0x20, 0x64, 0x6f,
+		0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x64, 0x6f, 0x63, 0x75, 0x6d,
+		0x65, 0x6e, 0x74, 0x45, 0x6c, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x3b, 0x0a,
+		0x09, 0x09, 0x76, 0x61, 0x72, 0x20, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74,
+		0x20, 0x3d, 0x20, 0x4d, 0x61, 0x74, 0x68, 0x2e, 0x63, 0x65, 0x69, 0x6c,
+		0x28, 0x4d, 0x61, 0x74, 0x68, 0x2e, 0x6d, 0x61, 0x78, 0x28, 0x65, 0x6c,
+		0x2e, 0x67, 0x65, 0x74, 0x42, 0x6f, 0x75, 0x6e, 0x64, 0x69, 0x6e, 0x67,
+		0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x63, 0x74, 0x28, 0x29,
+		0x2e, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x2c, 0x20, 0x64, 0x6f, 0x63,
+		0x75, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x62, 0x6f, 0x64, 0x79, 0x20, 0x3f,
+		0x20, 0x64, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x62, 0x6f,
+		0x64, 0x79, 0x2e, 0x73, 0x63, 0x72, 0x6f, 0x6c, 0x6c, 0x48, 0x65, 0x69,
+		0x67, 0x68, 0x74, 0x20, 0x3a, 0x20, 0x30, 0x29, 0x29, 0x3b, 0x0a, 0x09,
+		0x09, 0x69, 0x66, 0x20, 0x28, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x20,
+		0x3d, 0x3d, 0x3d, 0x20, 0x6c, 0x61, 0x73, 0x74, 0x29, 0x20, 0x72, 0x65,
+		0x74, 0x75, 0x72, 0x6e, 0x3b, 0x0a, 0x09, 0x09, 0x6c, 0x61, 0x73, 0x74,
+		0x20, 0x3d, 0x20, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x3b, 0x0a, 0x09,
+		0x09, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x2e, 0x70, 0x61, 0x72, 0x65,
+		0x6e, 0x74, 0x2e, 0x70, 0x6f, 0x73, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61,
+		0x67, 0x65, 0x28, 0x7b, 0x74, 0x79, 0x70, 0x65, 0x3a, 0x20, 0x22, 0x67,
+		0x69, 0x73, 0x74, 0x2d, 0x65, 0x78, 0x70, 0x6f, 0x73, 0x65, 0x64, 0x3a,
+		0x72, 0x65, 0x73, 0x69, 0x7a, 0x65, 0x22, 0x2c, 0x20, 0x68, 0x65, 0x69,
+		0x67, 0x68, 0x74, 0x3a, 0x20, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x7d,
+		0x2c, 0x20, 0x22, 0x2a, 0x22, 0x29, 0x3b, 0x0a, 0x09, 0x7d, 0x0a, 0x0a,
+		0x09, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x2e, 0x61, 0x64, 0x64, 0x45,
+		0x76, 0x65, 0x6e, 0x74, 0x4c, 0x69, 0x73, 0x74, 0x65, 0x6e, 0x65, 0x72,
+		0x28, 0x22, 0x6c, 0x6f, 0x61, 0x64, 0x22, 0x2c, 0x20, 0x70, 0x6f, 0x73,
+		0x74, 0x29, 0x3b, 0x0a, 0x09, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x2e,
+		0x61, 0x64, 0x64, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x4c, 0x69, 0x73, 0x74,
+		0x65, 0x6e, 0x65, 0x72, 0x28, 0x22, 0x72, 0x65, 0x73, 0x69, 0x7a, 0x65,
+		0x22, 0x2c, 0x20, 0x70, 0x6f, 0x73, 0x74, 0x29, 0x3b, 0x0a, 0x09, 0x69,
+		0x66, 0x20, 0x28, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x2e, 0x52, 0x65,
+		0x73, 0x69, 0x7a, 0x65, 0x4f, 0x62, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72,
+		0x29, 0x20, 0x7b, 0x0a, 0x09, 0x09, 0x6e, 0x65, 0x77, 0x20, 0x52, 0x65,
+		0x73, 0x69, 0x7a, 0x65, 0x4f, 0x62, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72,
+		0x28, 0x70, 0x6f, 0x73, 0x74, 0x29, 0x2e, 0x6f, 0x62, 0x73, 0x65, 0x72,
+		0x76, 0x65, 0x28, 0x64, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x2e,
+		0x64, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x45, 0x6c, 0x65, 0x6d,
+		0x65, 0x6e, 0x74, 0x29, 0x3b, 0x0a, 0x09, 0x7d, 0x20, 0x65, 0x6c, 0x73,
+		0x65, 0x20, 0x7b, 0x0a, 0x09, 0x09, 0x73, 0x65, 0x74, 0x49, 0x6e, 0x74,
+		0x65, 0x72, 0x76, 0x61, 0x6c, 0x28, 0x70, 0x6f, 0x73, 0x74, 0x2c, 0x20,
+		0x35, 0x30, 0x30, 0x29, 0x3b, 0x0a, 0x09, 0x7d, 0x0a, 0x09, 0x70, 0x6f,
+		0x73, 0x74, 0x28, 0x29, 0x3b, 0x0a, 0x7d, 0x29, 0x28, 0x29, 0x3b, 0x0a,
+	}
+}
+
+func codeCSS() []byte {
+	return []byte{
+		0x2f, 0x2a, 0x20, 0x63, 0x6f, 0x64, 0x65, 0x2e, 0x63, 0x73, 0x73, 0x20,
+		0x73, 0x74, 0x79, 0x6c, 0x65, 0x73, 0x20, 0x67, 0x69, 0x73, 0x74, 0x73,
+		0x20, 0x72, 0x65, 0x6e, 0x64, 0x65, 0x72, 0x65, 0x64, 0x20, 0x62, 0x79,
+		0x20, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x20, 0x65, 0x6d, 0x62, 0x65,
+		0x64, 0x73, 0x20, 0x61, 0x6e, 0x64, 0x20, 0x74, 0x68, 0x65, 0x20, 0x63,
+		0x6f, 0x64, 0x65, 0x20, 0x76, 0x69, 0x65, 0x77, 0x2e, 0x20, 0x2a, 0x2f,
+		0x0a, 0x2e, 0x67, 0x69, 0x73, 0x74, 0x2d, 0x65, 0x78, 0x70, 0x6f, 0x73,
+		0x65, 0x64, 0x2d, 0x70, 0x61, 0x67, 0x65, 0x20, 0x7b, 0x20, 0x6d, 0x61,
+		0x72, 0x67, 0x69, 0x6e, 0x3a, 0x20, 0x30, 0x3b, 0x20, 0x70, 0x61, 0x64,
+		0x64, 0x69, 0x6e, 0x67, 0x3a, 0x20, 0x38, 0x70, 0x78, 0x3b, 0x20, 0x7d,
+		0x0a, 0x2e, 0x67, 0x69, 0x73, 0x74, 0x2d, 0x65, 0x78, 0x70, 0x6f, 0x73,
+		0x65, 0x64, 0x2d, 0x65, 0x6d, 0x62, 0x65, 0x64, 0x20, 0x7b, 0x20, 0x6d,
+		0x61, 0x72, 0x67, 0x69, 0x6e, 0x2d, 0x62, 0x6f, 0x74, 0x74, 0x6f, 0x6d,
+		0x3a, 0x20, 0x31, 0x36, 0x70, 0x78, 0x3b, 0x20, 0x66, 0x6f, 0x6e, 0x74,
+		0x2d, 0x73, 0x69, 0x7a, 0x65, 0x3a, 0x20, 0x31, 0x32, 0x70, 0x78, 0x3b,
+		0x20, 0x6c, 0x69, 0x6e, 0x65, 0x2d, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74,
+		0x3a, 0x20, 0x31, 0x2e, 0x34, 0x3b, 0x20, 0x7d, 0x0a, 0x2e, 0x67, 0x69,
+		0x73, 0x74, 0x2d, 0x65, 0x78, 0x70, 0x6f, 0x73, 0x65, 0x64, 0x2d, 0x66,
+		0x69, 0x6c, 0x65, 0x20, 0x7b, 0x20, 0x6d, 0x61, 0x72, 0x67, 0x69, 0x6e,
+		0x2d, 0x62, 0x6f, 0x74, 0x74, 0x6f, 0x6d, 0x3a, 0x20, 0x31, 0x36, 0x70,
+		0x78, 0x3b, 0x20, 0x62, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x3a, 0x20, 0x31,
+		0x70, 0x78, 0x20, 0x73, 0x6f, 0x6c, 0x69, 0x64, 0x20, 0x23, 0x64, 0x64,
+		0x64, 0x3b, 0x20, 0x62, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x2d, 0x72, 0x61,
+		0x64, 0x69, 0x75, 0x73, 0x3a, 0x20, 0x33, 0x70, 0x78, 0x3b, 0x20, 0x6f,
+		0x76, 0x65, 0x72, 0x66, 0x6c, 0x6f, 0x77, 0x3a, 0x20, 0x68, 0x69, 0x64,
+		0x64, 0x65, 0x6e, 0x3b, 0x20, 0x62, 0x61, 0x63, 0x6b, 0x67, 0x72, 0x6f,
+		0x75, 0x6e, 0x64, 0x3a, 0x20, 0x23, 0x66, 0x66, 0x66, 0x3b, 0x20, 0x7d,
+		0x0a, 0x2e, 0x67, 0x69, 0x73, 0x74, 0x2d, 0x65, 0x78, 0x70, 0x6f, 0x73,
+		0x65, 0x64, 0x2d, 0x63, 0x6f, 0x64, 0x65, 0x20, 0x7b, 0x20, 0x77, 0x69,
+		0x64, 0x74, 0x68, 0x3a, 0x20, 0x31, 0x30, 0x30, 0x25, 0x3b, 0x20, 0x6d,
+		0x61, 0x72, 0x67, 0x69, 0x6e, 0x3a, 0x20, 0x30, 0x3b, 0x20, 0x62, 0x6f,
+		0x72, 0x64, 0x65, 0x72, 0x3a, 0x20, 0x6e, 0x6f, 0x6e, 0x65, 0x3b, 0x20,
+		0x62, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x2d, 0x63, 0x6f, 0x6c, 0x6c, 0x61,
+		0x70, 0x73, 0x65, 0x3a, 0x20, 0x63, 0x6f, 0x6c, 0x6c, 0x61, 0x70, 0x73,
+		0x65, 0x3b, 0x20, 0x66, 0x6f, 0x6e, 0x74, 0x2d, 0x66, 0x61, 0x6d, 0x69,
+		0x6c, 0x79, 0x3a, 0x20, 0x43, 0x6f, 0x6e, 0x73, 0x6f, 0x6c, 0x61, 0x73,
+		0x2c, 0x20, 0x22, 0x4c, 0x69, 0x62, 0x65, 0x72, 0x61, 0x74, 0x69, 0x6f,
+		0x6e, 0x20, 0x4d, 0x6f, 0x6e, 0x6f, 0x22, 0x2c, 0x20, 0x4d, 0x65, 0x6e,
+		0x6c, 0x6f, 0x2c, 0x20, 0x43, 0x6f, 0x75, 0x72, 0x69, 0x65, 0x72, 0x2c,
+		0x20, 0x6d, 0x6f, 0x6e, 0x6f, 0x73, 0x70, 0x61, 0x63, 0x65, 0x3b, 0x20,
+		0x7d, 0x0a, 0x2e, 0x67, 0x69, 0x73, 0x74, 0x2d, 0x65, 0x78, 0x70, 0x6f,
+		0x73, 0x65, 0x64, 0x2d, 0x63, 0x6f, 0x64, 0x65, 0x20, 0x74, 0x64, 0x20,
+		0x7b, 0x20, 0x70, 0x61, 0x64, 0x64, 0x69, 0x6e, 0x67, 0x3a, 0x20, 0x30,
+		0x20, 0x31, 0x30, 0x70, 0x78, 0x3b, 0x20, 0x62, 0x6f, 0x72, 0x64, 0x65,
+		0x72, 0x3a, 0x20, 0x6e, 0x6f, 0x6e, 0x65, 0x3b, 0x20, 0x76, 0x65, 0x72,
+		0x74, 0x69, 0x63, 0x61, 0x6c, 0x2d, 0x61, 0x6c, 0x69, 0x67, 0x6e, 0x3a,
+		0x20, 0x74, 0x6f, 0x70, 0x3b, 0x20, 0x7d, 0x0a, 0x2e, 0x67, 0x69, 0x73,
+		0x74, 0x2d, 0x65, 0x78, 0x70, 0x6f, 0x73, 0x65, 0x64, 0x2d, 0x63, 0x6f,
+		0x64, 0x65, 0x20, 0x74, 0x72, 0x3a, 0x74, 0x61, 0x72, 0x67, 0x65, 0x74,
+		0x20, 0x74, 0x64, 0x20, 0x7b, 0x20, 0x62, 0x61, 0x63, 0x6b, 0x67, 0x72,
+		0x6f, 0x75, 0x6e, 0x64, 0x3a, 0x20, 0x23, 0x66, 0x66, 0x66, 0x62, 0x64,
+		0x64, 0x3b, 0x20, 0x7d, 0x0a, 0x2e, 0x67, 0x69, 0x73, 0x74, 0x2d, 0x65,
+		0x78, 0x70, 0x6f, 0x73, 0x65, 0x64, 0x2d, 0x6e, 0x75, 0x6d, 0x20, 0x7b,
+		0x20, 0x77, 0x69, 0x64, 0x74, 0x68, 0x3a, 0x20, 0x31, 0x25, 0x3b, 0x20,
+		0x6d, 0x69, 0x6e, 0x2d, 0x77, 0x69, 0x64, 0x74, 0x68, 0x3a, 0x20, 0x34,
+		0x30, 0x70, 0x78, 0x3b, 0x20, 0x74, 0x65, 0x78, 0x74, 0x2d, 0x61, 0x6c,
+		0x69, 0x67, 0x6e, 0x3a, 0x20, 0x72, 0x69, 0x67, 0x68, 0x74, 0x3b, 0x20,
+		0x62, 0x61, 0x63, 0x6b, 0x67, 0x72, 0x6f, 0x75, 0x6e, 0x64, 0x3a, 0x20,
+		0x23, 0x66, 0x61, 0x66, 0x61, 0x66, 0x61, 0x3b, 0x20, 0x62, 0x6f, 0x72,
+		0x64, 0x65, 0x72, 0x2d, 0x72, 0x69, 0x67, 0x68, 0x74, 0x3a, 0x20, 0x31,
+		0x70, 0x78, 0x20, 0x73, 0x6f, 0x6c, 0x69, 0x64, 0x20, 0x23, 0x65, 0x65,
+		0x65, 0x20, 0x21, 0x69, 0x6d, 0x70, 0x6f, 0x72, 0x74, 0x61, 0x6e, 0x74,
+		0x3b, 0x20, 0x75, 0x73, 0x65, 0x72, 0x2d, 0x73, 0x65, 0x6c, 0x65, 0x63,
+		0x74, 0x3a, 0x20, 0x6e, 0x6f, 0x6e, 0x65, 0x3b, 0x20, 0x7d, 0x0a, 0x2e,
+		0x67, 0x69, 0x73, 0x74, 0x2d, 0x65, 0x78, 0x70, 0x6f, 0x73, 0x65, 0x64,
+		0x2d, 0x6e, 0x75, 0x6d, 0x20, 0x61, 0x20, 0x7b, 0x20, 0x63, 0x6f, 0x6c,
+		0x6f, 0x72, 0x3a, 0x20, 0x23, 0x39, 0x39, 0x39, 0x3b, 0x20, 0x74, 0x65,
+		0x78, 0x74, 0x2d, 0x64, 0x65, 0x63, 0x6f, 0x72, 0x61, 0x74, 0x69, 0x6f,
+		0x6e, 0x3a, 0x20, 0x6e, 0x6f, 0x6e, 0x65, 0x3b, 0x20, 0x7d, 0x0a, 0x2e,
+		0x67, 0x69, 0x73, 0x74, 0x2d, 0x65, 0x78, 0x70, 0x6f, 0x73, 0x65, 0x64,
+		0x2d, 0x6e, 0x75, 0x6d, 0x20, 0x61, 0x3a, 0x3a, 0x62, 0x65, 0x66, 0x6f,
+		0x72, 0x65, 0x20, 0x7b, 0x20, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74,
+		0x3a, 0x20, 0x61, 0x74, 0x74, 0x72, 0x28, 0x64, 0x61, 0x74, 0x61, 0x2d,
+		0x6c, 0x69, 0x6e, 0x65, 0x2d, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x29,
+		0x3b, 0x20, 0x7d, 0x0a, 0x2e, 0x67, 0x69, 0x73, 0x74, 0x2d, 0x65, 0x78,
+		0x70, 0x6f, 0x73, 0x65, 0x64, 0x2d, 0x6c, 0x69, 0x6e, 0x65, 0x20, 0x7b,
+		0x20, 0x77, 0x68, 0x69, 0x74, 0x65, 0x2d, 0x73, 0x70, 0x61, 0x63, 0x65,
+		0x3a, 0x20, 0x70, 0x72, 0x65, 0x3b, 0x20, 0x63, 0x6f, 0x6c, 0x6f, 0x72,
+		0x3a, 0x20, 0x23, 0x33, 0x33, 0x33, 0x3b, 0x20, 0x7d, 0x0a, 0x2e, 0x67,
+		0x69, 0x73, 0x74, 0x2d, 0x65, 0x78, 0x70, 0x6f, 0x73, 0x65, 0x64, 0x2d,
+		0x62, 0x69, 0x6e, 0x61, 0x72, 0x79, 0x20, 0x7b, 0x20, 0x70, 0x61, 0x64,
+		0x64, 0x69, 0x6e, 0x67, 0x3a, 0x20, 0x31, 0x30, 0x70, 0x78, 0x3b, 0x20,
+		0x63, 0x6f, 0x6c, 0x6f, 0x72, 0x3a, 0x20, 0x23, 0x37, 0x37, 0x37, 0x3b,
+		0x20, 0x7d, 0x0a, 0x2e, 0x67, 0x69, 0x73, 0x74, 0x2d, 0x65, 0x78, 0x70,
+		0x6f, 0x73, 0x65, 0x64, 0x2d, 0x6d, 0x65, 0x74, 0x61, 0x20, 0x7b, 0x20,
+		0x70, 0x61, 0x64, 0x64, 0x69, 0x6e, 0x67, 0x3a, 0x20, 0x31, 0x30, 0x70,
+		0x78, 0x3b, 0x20, 0x6f, 0x76, 0x65, 0x72, 0x66, 0x6c, 0x6f, 0x77, 0x3a,
+		0x20, 0x68, 0x69, 0x64, 0x64, 0x65, 0x6e, 0x3b, 0x20, 0x66, 0x6f, 0x6e,
+		0x74, 0x2d, 0x66, 0x61, 0x6d, 0x69, 0x6c, 0x79, 0x3a, 0x20, 0x48, 0x65,
+		0x6c, 0x76, 0x65, 0x74, 0x69, 0x63, 0x61, 0x2c, 0x20, 0x41, 0x72, 0x69,
+		0x61, 0x6c, 0x2c, 0x20, 0x73, 0x61, 0x6e, 0x73, 0x2d, 0x73, 0x65, 0x72,
+		0x69, 0x66, 0x3b, 0x20, 0x63, 0x6f, 0x6c, 0x6f, 0x72, 0x3a, 0x20, 0x23,
+		0x36, 0x36, 0x36, 0x3b, 0x20, 0x62, 0x61, 0x63, 0x6b, 0x67, 0x72, 0x6f,
+		0x75, 0x6e, 0x64, 0x3a, 0x20, 0x23, 0x66, 0x37, 0x66, 0x37, 0x66, 0x37,
+		0x3b, 0x20, 0x62, 0x6f, 0x72, 0x64, 0x65, 0x72, 0x2d, 0x74, 0x6f, 0x70,
+		0x3a, 0x20, 0x31, 0x70, 0x78, 0x20, 0x73, 0x6f, 0x6c, 0x69, 0x64, 0x20,
+		0x23, 0x64, 0x64, 0x64, 0x3b, 0x20, 0x7d, 0x0a, 0x2e, 0x67, 0x69, 0x73,
+		0x74, 0x2d, 0x65, 0x78, 0x70, 0x6f, 0x73, 0x65, 0x64, 0x2d, 0x6d, 0x65,
+		0x74, 0x61, 0x20, 0x61, 0x20, 0x7b, 0x20, 0x63, 0x6f, 0x6c, 0x6f, 0x72,
+		0x3a, 0x20, 0x23, 0x36, 0x36, 0x36, 0x3b, 0x20, 0x66, 0x6f, 0x6e, 0x74,
+		0x2d, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x3a, 0x20, 0x62, 0x6f, 0x6c,
+		0x64, 0x3b, 0x20, 0x74, 0x65, 0x78, 0x74, 0x2d, 0x64, 0x65, 0x63, 0x6f,
+		0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x3a, 0x20, 0x6e, 0x6f, 0x6e, 0x65,
+		0x3b, 0x20, 0x7d, 0x0a, 0x2e, 0x67, 0x69, 0x73, 0x74, 0x2d, 0x65, 0x78,
+		0x70, 0x6f, 0x73, 0x65, 0x64, 0x2d, 0x72, 0x61, 0x77, 0x20, 0x7b, 0x20,
+		0x66, 0x6c, 0x6f, 0x61, 0x74, 0x3a, 0x20, 0x72, 0x69, 0x67, 0x68, 0x74,
+		0x3b, 0x20, 0x7d, 0x0a,
+	}
+}
@@ -0,0 +1,23 @@
+// embed.js is included in oEmbed HTML. It listens for the height reported by
+// resize.js in a hosted page and resizes the matching embed to fit.
+(function() {
+	if (window.gistExposedEmbed) return;
+	window.gistExposedEmbed = true;
+
+	window.addEventListener("message", function(event) {
+		var data = event.data;
+		if (!data || data.type !== "gist-exposed:resize" || typeof data.height !== "number") return;
+
+		var frames = document.querySelectorAll(".gist-exposed iframe");
+		for (var i = 0; i < frames.length; i++) {
+			if (frames[i].contentWindow !== event.source) continue;
+
+			// Never grow past the consumer's maximum height, if there is one.
+			var div = frames[i].parentNode;
+			var height = Math.max(0, Math.floor(data.height));
+			var max = parseInt(div.getAttribute("data-max-height"), 10);
+			if (max > 0 && height > max) height = max;
+			div.style.paddingBottom = height + "px";
+		}
+	});
+})();
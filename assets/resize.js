@@ -0,0 +1,23 @@
+// resize.js is added to hosted HTML pages. It reports the height of the page
+// to the embedding page so that embed.js can resize the iframe to fit.
+(function() {
+	if (window.parent === window) return;
+
+	var last = -1;
+	function post() {
+		var el = document.documentElement;
+		var height = Math.ceil(Math.max(el.getBoundingClientRect().height, document.body ? document.body.scrollHeight : 0));
+		if (height === last) return;
+		last = height;
+		window.parent.postMessage({type: "gist-exposed:resize", height: height}, "*");
+	}
+
+	window.addEventListener("load", post);
+	window.addEventListener("resize", post);
+	if (window.ResizeObserver) {
+		new ResizeObserver(post).observe(document.documentElement);
+	} else {
+		setInterval(post, 500);
+	}
+	post();
+})();
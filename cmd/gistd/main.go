@@ -20,6 +20,37 @@ func main() {
 		cert    = flag.String("cert", "", "SSL certificate file")
 		key     = flag.String("key", "", "SSL key file")
 		bskey   = flag.String("bugsnag", "", "bugsnag key")
+
+		reloadInterval     = flag.Duration("reload-interval", gist.DefaultReloadInterval, "minimum time between reloads of a gist")
+		userReloadInterval = flag.Duration("user-reload-interval", gist.DefaultUserReloadInterval, "time for a user to earn another reload")
+		userReloadBurst    = flag.Int("user-reload-burst", gist.DefaultUserReloadBurst, "maximum reloads a user can perform at once")
+
+		cacheMaxAge    = flag.Int("cache-max-age", gist.DefaultCacheMaxAge, "seconds gist files can be cached")
+		cacheStale     = flag.Int("cache-stale-while-revalidate", gist.DefaultCacheStaleWhileRevalidate, "seconds stale gist files can be served while revalidating")
+		cacheImmutable = flag.Bool("cache-immutable", false, "mark gist files as immutable")
+		embedCacheAge  = flag.Int("embed-cache-age", gist.DefaultEmbedCacheAge, "seconds consumers can cache an oEmbed response")
+
+		contentOrigin = flag.String("content-origin", "", "separate origin to serve gist files from, e.g. https://usercontent.example.com")
+		contentCSP    = flag.String("content-security-policy", gist.DefaultContentSecurityPolicy, "Content-Security-Policy sent with gist files")
+		appOrigin     = flag.String("app-origin", "", "origin of the application, e.g. https://gist.example.com (default: request host)")
+
+		oembedDiscovery = flag.Bool("oembed-discovery", true, "add oEmbed discovery links to HTML gist files")
+		embedResize     = flag.Bool("embed-resize", true, "resize embedded gists to fit their content")
+
+		syncInterval = flag.Duration("sync-interval", gist.DefaultSyncInterval, "time between background syncs (0 to disable)")
+		syncWorkers  = flag.Int("sync-workers", gist.DefaultSyncConcurrency, "number of gists synced in parallel")
+
+		githubAPIURL    = flag.String("github-api-url", gist.DefaultGitHubAPIURL, "GitHub API base URL")
+		githubUploadURL = flag.String("github-upload-url", gist.DefaultGitHubUploadURL, "GitHub upload base URL")
+		githubRawHost   = flag.String("github-raw-host", "", "host for raw gist file downloads (default: as returned by the API)")
+		githubAuthURL   = flag.String("github-auth-url", gist.DefaultGitHubAuthURL, "GitHub OAuth authorization URL")
+		githubTokenURL  = flag.String("github-token-url", gist.DefaultGitHubTokenURL, "GitHub OAuth token URL")
+
+		maxFileSize = flag.Int64("max-file-size", gist.DefaultMaxFileSize, "maximum size of a gist file in bytes (0 for no limit)")
+		maxGistSize = flag.Int64("max-gist-size", gist.DefaultMaxGistSize, "maximum total size of a gist in bytes (0 for no limit)")
+		dirSep      = flag.String("dir-separator", gist.DefaultDirSeparator, "separator in gist filenames that maps to nested paths (blank to disable)")
+		precompress = flag.Bool("precompress", true, "store gzip and brotli variants of text files")
+		fetchMode   = flag.String("fetch-mode", gist.FetchModeRaw, "how gist content is retrieved: raw or git")
 	)
 	flag.Parse()
 	log.SetFlags(0)
@@ -42,6 +73,10 @@ func main() {
 		log.Fatal("key file required: -key PATH")
 	} else if *key != "" && *cert == "" {
 		log.Fatal("certificate file required: -cert PATH")
+	} else if *contentOrigin != "" && *appOrigin == "" {
+		log.Fatal("app origin required with a content origin: -app-origin URL")
+	} else if *fetchMode != gist.FetchModeRaw && *fetchMode != gist.FetchModeGit {
+		log.Fatal("invalid fetch mode: -fetch-mode raw|git")
 	}
 
 	// Make sure the data directory exists.
@@ -52,13 +87,48 @@ func main() {
 	// Open the database.
 	var db gist.DB
 	db.GistPath = filepath.Join(*datadir, "gists")
+	db.MaxFileSize = *maxFileSize
+	db.MaxGistSize = *maxGistSize
+	db.FetchMode = *fetchMode
+	db.DirSeparator = *dirSep
+	db.Precompress = *precompress
+	db.GitHub = &gist.GitHubConfig{
+		APIURL:    *githubAPIURL,
+		UploadURL: *githubUploadURL,
+		RawHost:   *githubRawHost,
+		AuthURL:   *githubAuthURL,
+		TokenURL:  *githubTokenURL,
+	}
 	if err := db.Open(filepath.Join(*datadir, "db"), 0600); err != nil {
 		log.Fatal(err)
 	}
 	defer func() { _ = db.Close() }()
 
+	// Start syncing hosted gists in the background.
+	syncer := gist.NewSyncer(&db)
+	syncer.Interval = *syncInterval
+	syncer.Concurrency = *syncWorkers
+	if err := syncer.Open(); err != nil {
+		log.Fatal(err)
+	}
+	defer func() { _ = syncer.Close() }()
+
 	// Initialize the handler.
 	h := gist.NewHandler(&db, *token, *secret)
+	h.ReloadInterval = *reloadInterval
+	h.UserReloadInterval = *userReloadInterval
+	h.UserReloadBurst = *userReloadBurst
+	h.CachePolicy = gist.CachePolicy{
+		MaxAge:               *cacheMaxAge,
+		StaleWhileRevalidate: *cacheStale,
+		Immutable:            *cacheImmutable,
+	}
+	h.EmbedCacheAge = *embedCacheAge
+	h.ContentOrigin = *contentOrigin
+	h.ContentSecurityPolicy = *contentCSP
+	h.AppOrigin = *appOrigin
+	h.OEmbedDiscovery = *oembedDiscovery
+	h.EmbedResize = *embedResize
 
 	// Start HTTP server.
 	if *cert != "" && *key != "" {
@@ -0,0 +1,198 @@
+package gist
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Content encodings of precompressed blobs.
+const (
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
+)
+
+// minCompressSize is the smallest blob that is precompressed. Smaller files
+// gain little from compression and may even grow.
+const minCompressSize = 256
+
+// Compression levels of precompressed blobs. Variants are created while a
+// gist is reloaded so moderate levels keep reloads fast while still getting
+// most of the size reduction of the best levels.
+const (
+	brotliLevel = 5
+	gzipLevel   = gzip.DefaultCompression
+)
+
+// encodingExts maps content encodings to the extension of the compressed
+// variant in the blob store. Encodings are listed in order of preference.
+var encodingExts = []struct {
+	encoding, ext string
+}{
+	{EncodingBrotli, ".br"},
+	{EncodingGzip, ".gz"},
+}
+
+// compressibleExts are the extensions of text files that are precompressed.
+var compressibleExts = map[string]bool{
+	".css": true, ".csv": true, ".htm": true, ".html": true, ".js": true,
+	".json": true, ".map": true, ".md": true, ".mjs": true, ".svg": true,
+	".tsv": true, ".txt": true, ".wasm": true, ".xml": true,
+}
+
+// Compressible returns true if files with the given name are precompressed.
+func Compressible(filename string) bool {
+	return compressibleExts[strings.ToLower(filepath.Ext(filename))]
+}
+
+// CompressedBlobPath returns the path to a compressed variant of a blob.
+// Returns a blank path if the hash or encoding is invalid.
+func (db *DB) CompressedBlobPath(hash, encoding string) string {
+	ext := encodingExt(encoding)
+	if !isBlobHash(hash) || ext == "" {
+		return ""
+	}
+	path, _ := db.Root().Path("blobs", hash[:2], hash[2:]+ext)
+	return path
+}
+
+// OpenCompressedBlob opens a compressed variant of a blob for reading.
+func (db *DB) OpenCompressedBlob(hash, encoding string) (*os.File, error) {
+	ext := encodingExt(encoding)
+	if !isBlobHash(hash) {
+		return nil, fmt.Errorf("invalid blob hash: %q", hash)
+	} else if ext == "" {
+		return nil, fmt.Errorf("invalid encoding: %q", encoding)
+	}
+	return db.Root().Open("blobs", hash[:2], hash[2:]+ext)
+}
+
+// compressGist creates the missing compressed variants of the text files in
+// a gist. Blobs are immutable so each variant is only created once. Failures
+// are not fatal since the uncompressed blob can always be served.
+func (db *DB) compressGist(gist *Gist) {
+	for _, file := range gist.Files {
+		if !Compressible(file.Filename) || file.Size < minCompressSize {
+			continue
+		}
+		for _, e := range encodingExts {
+			if err := db.compressBlob(file.Hash, e.encoding); err != nil {
+				warnf("compress: %s/%s: %s: %s", gist.ID, file.Filename, e.encoding, err)
+			}
+		}
+	}
+}
+
+// compressBlobs creates the missing compressed variants of a blob.
+func (db *DB) compressBlobs(hash string) {
+	for _, e := range encodingExts {
+		if err := db.compressBlob(hash, e.encoding); err != nil {
+			warnf("compress: %s: %s: %s", hash, e.encoding, err)
+		}
+	}
+}
+
+// compressBlob writes a compressed variant of a blob to the blob store.
+func (db *DB) compressBlob(hash, encoding string) error {
+	dst := db.CompressedBlobPath(hash, encoding)
+	if dst == "" {
+		return fmt.Errorf("invalid blob: %s", hash)
+	} else if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+
+	src, err := db.OpenBlob(hash)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	// Compress into the staging directory and then move it into place so
+	// a partially written variant is never served.
+	dir, err := db.mkstage("compress")
+	if err != nil {
+		return fmt.Errorf("staging: %s", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, "0")
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	var w io.WriteCloser
+	switch encoding {
+	case EncodingBrotli:
+		w = brotli.NewWriterLevel(f, brotliLevel)
+	case EncodingGzip:
+		w, _ = gzip.NewWriterLevel(f, gzipLevel)
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	} else if err := w.Close(); err != nil {
+		return err
+	} else if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(path, dst)
+}
+
+// encodingExt returns the blob extension for an encoding.
+func encodingExt(encoding string) string {
+	for _, e := range encodingExts {
+		if e.encoding == encoding {
+			return e.ext
+		}
+	}
+	return ""
+}
+
+// AcceptedEncodings parses an Accept-Encoding header and returns the
+// supported encodings the client accepts, most preferred first.
+func AcceptedEncodings(header string) []string {
+	q := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		// Split the coding from its quality value, if any.
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		weight := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					weight = v
+				}
+			}
+		}
+
+		// A wildcard applies to all encodings not listed explicitly.
+		if coding == "*" {
+			for _, e := range encodingExts {
+				if _, ok := q[e.encoding]; !ok {
+					q[e.encoding] = weight
+				}
+			}
+		} else if encodingExt(coding) != "" {
+			q[coding] = weight
+		}
+	}
+
+	var a []string
+	for _, e := range encodingExts {
+		if q[e.encoding] > 0 {
+			a = append(a, e.encoding)
+		}
+	}
+	sort.SliceStable(a, func(i, j int) bool { return q[a[i]] > q[a[j]] })
+	return a
+}
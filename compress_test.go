@@ -0,0 +1,210 @@
+package gist_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/benbjohnson/gist"
+)
+
+// Ensure that the Accept-Encoding header is negotiated correctly.
+func TestAcceptedEncodings(t *testing.T) {
+	var tests = []struct {
+		header    string
+		encodings []string
+	}{
+		{"", nil},
+		{"identity", nil},
+		{"gzip", []string{"gzip"}},
+		{"gzip, deflate, br", []string{"br", "gzip"}},
+		{"GZIP;q=0.9, br;q=0.5", []string{"gzip", "br"}},
+		{"br;q=0, gzip", []string{"gzip"}},
+		{"*", []string{"br", "gzip"}},
+		{"*;q=0.1, gzip", []string{"gzip", "br"}},
+		{"*, br;q=0", []string{"gzip"}},
+	}
+	for i, tt := range tests {
+		if a := gist.AcceptedEncodings(tt.header); !reflect.DeepEqual(tt.encodings, a) {
+			t.Errorf("%d. %q: exp: %v, got: %v", i, tt.header, tt.encodings, a)
+		}
+	}
+}
+
+// Ensure that only text files are compressed.
+func TestCompressible(t *testing.T) {
+	equals(t, true, gist.Compressible("index.html"))
+	equals(t, true, gist.Compressible("css/STYLE.CSS"))
+	equals(t, false, gist.Compressible("logo.png"))
+	equals(t, false, gist.Compressible("README"))
+}
+
+// Ensure that compressed variants of text files are created when a gist is loaded.
+func TestDB_LoadGist_Precompress(t *testing.T) {
+	content := strings.Repeat("<p>hello, world</p>\n", 100)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.html", "/logo.png":
+			w.Write([]byte(content))
+		default:
+			w.Write([]byte("small"))
+		}
+	}))
+	defer s.Close()
+
+	db := NewTestDB()
+	defer db.Close()
+	db.Precompress = true
+	db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"})
+
+	client := &MockGitHubClient{}
+	client.GistFunc = func(id string) (*gist.Gist, error) {
+		return &gist.Gist{ID: "xxx", UserID: 1000, Files: []*gist.GistFile{
+			{Filename: "index.html", RawURL: s.URL + "/index.html"},
+			{Filename: "logo.png", RawURL: s.URL + "/logo.png"},
+			{Filename: "small.js", RawURL: s.URL + "/small.js"},
+		}}, nil
+	}
+	db.NewGitHubClient = func(_ string) gist.GitHubClient { return client }
+	ok(t, db.LoadGist(1000, "xxx"))
+
+	// Verify that both variants decompress to the original content.
+	hash := MustWriteBlob(db.DB, content)
+	b, err := ioutil.ReadFile(db.CompressedBlobPath(hash, gist.EncodingGzip))
+	ok(t, err)
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	ok(t, err)
+	b, _ = ioutil.ReadAll(zr)
+	equals(t, content, string(b))
+
+	b, err = ioutil.ReadFile(db.CompressedBlobPath(hash, gist.EncodingBrotli))
+	ok(t, err)
+	b, _ = ioutil.ReadAll(brotli.NewReader(bytes.NewReader(b)))
+	equals(t, content, string(b))
+
+	// Small files are not compressed.
+	_, err = os.Stat(db.CompressedBlobPath(MustWriteBlob(db.DB, "small"), gist.EncodingGzip))
+	assert(t, os.IsNotExist(err), "expected no variant for small file: %v", err)
+
+	// Binary files are not compressed. Only the text file's variants exist.
+	matches, _ := filepath.Glob(filepath.Join(db.GistPath, "blobs", "*", "*.gz"))
+	equals(t, 1, len(matches))
+}
+
+// Ensure that a precompressed variant is served if the client accepts it.
+func TestHandler_Gist_Compressed(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+
+	// Save the file and a gzip variant.
+	hash := MustWriteBlob(h.DB, `hello, world`)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`hello, world`))
+	zw.Close()
+	path := h.DB.CompressedBlobPath(hash, gist.EncodingGzip)
+	ok(t, ioutil.WriteFile(path, buf.Bytes(), 0600))
+
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "xxx", Files: []*gist.GistFile{
+			{Filename: "index.html", Hash: hash},
+		}})
+	})
+
+	// The gzip variant should be served since there is no brotli variant.
+	req, _ := http.NewRequest("GET", h.Server.URL+"/xxx/index.html", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	resp, err := http.DefaultClient.Do(req)
+	ok(t, err)
+	equals(t, 200, resp.StatusCode)
+	equals(t, "gzip", resp.Header.Get("Content-Encoding"))
+	equals(t, "Accept-Encoding", resp.Header.Get("Vary"))
+	equals(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	equals(t, `"`+hash+`-gzip"`, resp.Header.Get("ETag"))
+	equals(t, buf.String(), readall(resp.Body))
+	resp.Body.Close()
+
+	// The original should be served if the client does not accept gzip.
+	req, _ = http.NewRequest("GET", h.Server.URL+"/xxx/index.html", nil)
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err = http.DefaultClient.Do(req)
+	ok(t, err)
+	equals(t, "", resp.Header.Get("Content-Encoding"))
+	equals(t, "Accept-Encoding", resp.Header.Get("Vary"))
+	equals(t, `"`+hash+`"`, resp.Header.Get("ETag"))
+	equals(t, `hello, world`, readall(resp.Body))
+	resp.Body.Close()
+}
+
+// Ensure that HTML files with inserted markup are also served precompressed.
+func TestHandler_Gist_CompressedHead(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+	h.DB.Precompress = true
+	h.OEmbedDiscovery = true
+	h.AppOrigin = "https://gist.example.com"
+
+	page := `<html><head></head><body>` + strings.Repeat("hello, world ", 50) + `</body></html>`
+	hash := MustWriteBlob(h.DB, page)
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "xxx", Files: []*gist.GistFile{
+			{Filename: "index.html", Hash: hash, Size: len(page)},
+		}})
+	})
+
+	// The uncompressed page includes the inserted links.
+	req, _ := http.NewRequest("GET", h.Server.URL+"/xxx/index.html", nil)
+	req.Header.Set("Accept-Encoding", "identity")
+	resp, err := http.DefaultClient.Do(req)
+	ok(t, err)
+	equals(t, 200, resp.StatusCode)
+	equals(t, "", resp.Header.Get("Content-Encoding"))
+	etag := resp.Header.Get("ETag")
+	assert(t, strings.HasPrefix(etag, `"`+hash+`-`), "unexpected etag: %s", etag)
+	body := readall(resp.Body)
+	resp.Body.Close()
+	assert(t, strings.Contains(body, `<link rel="alternate"`), "unexpected body: %s", body)
+
+	// The same page is served gzipped with its own tag once the variant has
+	// been created in the background.
+	var encoding string
+	for i := 0; i < 100 && encoding == ""; i++ {
+		req, _ = http.NewRequest("GET", h.Server.URL+"/xxx/index.html", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err = http.DefaultTransport.RoundTrip(req)
+		ok(t, err)
+		equals(t, 200, resp.StatusCode)
+		if encoding = resp.Header.Get("Content-Encoding"); encoding == "" {
+			resp.Body.Close()
+			time.Sleep(10 * time.Millisecond)
+			continue
+		}
+		equals(t, "gzip", encoding)
+		equals(t, strings.TrimSuffix(etag, `"`)+`-gzip"`, resp.Header.Get("ETag"))
+		zr, err := gzip.NewReader(resp.Body)
+		ok(t, err)
+		equals(t, body, readall(zr))
+		resp.Body.Close()
+	}
+	equals(t, "gzip", encoding)
+
+	// Pages whose markup depends on the query are not stored or compressed.
+	req, _ = http.NewRequest("GET", h.Server.URL+"/xxx/index.html?x=1", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err = http.DefaultTransport.RoundTrip(req)
+	ok(t, err)
+	equals(t, 200, resp.StatusCode)
+	equals(t, "", resp.Header.Get("Content-Encoding"))
+	body = readall(resp.Body)
+	resp.Body.Close()
+	assert(t, strings.Contains(body, `index.html%3Fx%3D1`), "unexpected body: %s", body)
+}
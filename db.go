@@ -3,27 +3,82 @@ package gist
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/boltdb/bolt"
 )
 
+const (
+	// DefaultDirSeparator is the default separator for nested paths in gist filenames.
+	DefaultDirSeparator = "__"
+
+	// DefaultMaxFileSize is the default size limit of a single gist file.
+	DefaultMaxFileSize = 10 << 20
+
+	// DefaultMaxGistSize is the default size limit of all files in a gist.
+	DefaultMaxGistSize = 100 << 20
+)
+
+// Fetch modes for retrieving gist content.
+const (
+	// FetchModeRaw downloads each file from its raw URL.
+	FetchModeRaw = "raw"
+
+	// FetchModeGit clones the gist's git repository and reads the files
+	// from the gist's revision.
+	FetchModeGit = "git"
+)
+
 // DB represents the application-level database.
 type DB struct {
 	*bolt.DB
 	secret []byte
 
+	// Concurrent reloads of the same gist share a single refresh.
+	loads flightGroup
+
 	// GistPath to the root of the gist data.
 	GistPath string
 
+	// GitHub holds the GitHub endpoints. Defaults to github.com if nil.
+	GitHub *GitHubConfig
+
 	// NewGitHubClient is the function used to return a new github client.
 	NewGitHubClient func(string) GitHubClient
+
+	// Maximum size, in bytes, of a single gist file and of all files in a
+	// gist. Gists exceeding a limit are not loaded. Zero means no limit.
+	MaxFileSize int64
+	MaxGistSize int64
+
+	// FetchMode selects how gist content is retrieved from GitHub.
+	// Defaults to FetchModeRaw.
+	FetchMode string
+
+	// DirSeparator maps nested paths to gist filenames since gist filenames
+	// cannot contain slashes. For example, "css/style.css" maps to the file
+	// "css__style.css" with a separator of "__". Blank disables nested paths.
+	DirSeparator string
+
+	// Precompress creates gzip and brotli variants of text files in the blob
+	// store when a gist is loaded so they can be served without compressing
+	// on each request.
+	Precompress bool
 }
 
 // Open opens and initializes the database.
@@ -35,16 +90,19 @@ func (db *DB) Open(path string, mode os.FileMode) error {
 	db.DB = d
 
 	if db.NewGitHubClient == nil {
-		db.NewGitHubClient = NewGitHubClient
+		db.NewGitHubClient = db.GitHub.NewClient
 	}
 
 	return db.Update(func(tx *Tx) error {
 		// Initialize the top-level buckets.
 		_, _ = tx.CreateBucketIfNotExists([]byte("meta"))
 		_, _ = tx.CreateBucketIfNotExists([]byte("gists"))
+		_, _ = tx.CreateBucketIfNotExists([]byte("gistRevisions"))
+		_, _ = tx.CreateBucketIfNotExists([]byte("gistSyncs"))
 		_, _ = tx.CreateBucketIfNotExists([]byte("users"))
 
 		_, _ = tx.CreateBucketIfNotExists([]byte("gistsByUserID"))
+		_, _ = tx.CreateBucketIfNotExists([]byte("gistLists"))
 
 		// Initialize secret.
 		if err := tx.GenerateSecretIfNotExists(); err != nil {
@@ -76,59 +134,484 @@ func (db *DB) Secret() []byte {
 }
 
 // LoadGist retrieves the latest gist files from GitHub.
+// Files are downloaded into a staging directory and are only moved into the
+// blob store once every file has been retrieved. The gist's manifest is then
+// swapped in a single write transaction. The staging directory is always removed.
+//
+// GitHub is accessed outside of any transaction so slow downloads do not
+// block other writers. Concurrent loads of the same gist share one refresh
+// and all receive its result.
 func (db *DB) LoadGist(userID int, gistID string) error {
-	return db.Update(func(tx *Tx) error {
-		// Retrieve user.
-		u, err := tx.User(userID)
-		if err != nil {
+	return db.loads.Do(gistID, func() error { return db.loadGist(userID, gistID) })
+}
+
+func (db *DB) loadGist(userID int, gistID string) error {
+	if !ValidGistID(gistID) {
+		return fmt.Errorf("invalid gist id: %q", gistID)
+	}
+
+	// Retrieve user and the previous version so unchanged files can be reused.
+	var u *User
+	var prev *Gist
+	err := db.View(func(tx *Tx) (err error) {
+		if u, err = tx.User(userID); err != nil {
 			return fmt.Errorf("user: %s", err)
 		} else if u == nil {
 			return fmt.Errorf("user not found: %d", userID)
 		}
 
-		// Create GitHub client.
-		client := db.NewGitHubClient(u.AccessToken)
+		if prev, err = tx.Gist(gistID); err != nil {
+			return fmt.Errorf("previous gist: %s", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		// Retrieve gist data.
-		gist, err := client.Gist(gistID)
-		if err != nil {
-			return fmt.Errorf("gist: %s", err)
-		} else if gist == nil {
-			return fmt.Errorf("gist not found: %s", gistID)
+	// Create GitHub client.
+	client := db.NewGitHubClient(u.AccessToken)
+
+	// Retrieve gist data. Use a conditional request if the gist has been
+	// retrieved before and skip the refresh if nothing has changed.
+	var gist *Gist
+	if prev != nil && (prev.ETag != "" || prev.LastModified != "") {
+		gist, err = client.GistIfModified(gistID, prev.ETag, prev.LastModified)
+	} else {
+		gist, err = client.Gist(gistID)
+	}
+	if err == ErrNotModified {
+		return db.touchGist(gistID)
+	} else if _, ok := err.(*RateLimitError); ok {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("gist: %s", err)
+	} else if gist == nil {
+		return fmt.Errorf("gist not found: %s", gistID)
+	} else if err := validateGist(gist, gistID); err != nil {
+		return err
+	}
+
+	// Download all files into a staging directory.
+	dir, err := db.stageGist(gist, prev)
+	defer func() { _ = os.RemoveAll(dir) }()
+	if err != nil {
+		return err
+	}
+
+	// Read the gist's configuration file, if it has one.
+	gist.Config = db.readGistConfig(gist, dir)
+
+	// Move the staged files into the blob store and save to the database.
+	gist.SyncedAt = time.Now().UTC()
+	if err := db.Update(func(tx *Tx) error { return db.commitGist(tx, dir, gist) }); err != nil {
+		return fmt.Errorf("commit gist: %s", err)
+	}
+
+	// Create compressed variants outside of the transaction.
+	if db.Precompress {
+		db.compressGist(gist)
+	}
+
+	return nil
+}
+
+// touchGist records that a gist was verified as unchanged with GitHub.
+func (db *DB) touchGist(gistID string) error {
+	err := db.Update(func(tx *Tx) error {
+		g, err := tx.Gist(gistID)
+		if err != nil || g == nil {
+			return err
+		}
+		g.SyncedAt = time.Now().UTC()
+		return tx.SaveGist(g)
+	})
+	if err != nil {
+		return fmt.Errorf("touch gist: %s", err)
+	}
+	return nil
+}
+
+// validateGist verifies that the gist returned by GitHub matches the requested
+// ID and that its filenames are safe to serve.
+func validateGist(gist *Gist, gistID string) error {
+	if gist.ID != gistID {
+		return fmt.Errorf("gist id mismatch: %q != %q", gist.ID, gistID)
+	}
+
+	m := make(map[string]bool)
+	for _, file := range gist.Files {
+		if !ValidFilename(file.Filename) {
+			return fmt.Errorf("invalid filename: %q", file.Filename)
+		} else if m[file.Filename] {
+			return fmt.Errorf("duplicate filename: %q", file.Filename)
+		}
+		m[file.Filename] = true
+	}
+	return nil
+}
+
+// stageGist downloads the files of a gist into a new staging directory and
+// sets the hash on each file. Files matching the previous version of the gist
+// are reused from the blob store. The caller must remove the staging directory.
+func (db *DB) stageGist(gist, prev *Gist) (string, error) {
+	dir, err := db.mkstage(gist.ID)
+	if err != nil {
+		return "", fmt.Errorf("staging: %s", err)
+	}
+
+	// Check the sizes reported by GitHub before downloading anything.
+	var total int64
+	for _, file := range gist.Files {
+		if db.MaxFileSize > 0 && int64(file.Size) > db.MaxFileSize {
+			return dir, &SizeLimitError{GistID: gist.ID, Filename: file.Filename, Limit: db.MaxFileSize}
+		}
+		total += int64(file.Size)
+	}
+	if db.MaxGistSize > 0 && total > db.MaxGistSize {
+		return dir, &SizeLimitError{GistID: gist.ID, Limit: db.MaxGistSize}
+	}
+
+	// Fetch the file content into the staging directory.
+	switch db.FetchMode {
+	case FetchModeGit:
+		err = db.fetchGit(gist, dir)
+	default:
+		err = db.fetchRaw(gist, prev, dir)
+	}
+	if err != nil {
+		return dir, err
+	}
+
+	// Verify that every file is either staged or already in the blob store.
+	total = 0
+	for i, file := range gist.Files {
+		if _, err := os.Stat(stagedFilePath(dir, i)); os.IsNotExist(err) && !db.BlobExists(file.Hash) {
+			return dir, fmt.Errorf("file not staged: %s", file.Filename)
 		}
+		total += int64(file.Size)
+	}
+
+	// Verify the actual size of the gist since reported sizes may be wrong.
+	if db.MaxGistSize > 0 && total > db.MaxGistSize {
+		return dir, &SizeLimitError{GistID: gist.ID, Limit: db.MaxGistSize}
+	}
+
+	return dir, nil
+}
+
+// fetchRaw downloads the files of a gist into the staging directory.
+func (db *DB) fetchRaw(gist, prev *Gist, dir string) error {
+	// Download all files over HTTP. Raw URLs are unique to the file
+	// content so matching files do not need to be downloaded again.
+	// Complete text content returned inline by the API is used as-is.
+	ch := make(chan error, len(gist.Files))
+	for i, file := range gist.Files {
+		go func(file *GistFile, path string) {
+			defer autonotify()
+			if f := prev.fileByRawURL(file.RawURL); f != nil && f.Hash != "" && db.BlobExists(f.Hash) {
+				file.Hash = f.Hash
+				ch <- nil
+				return
+			}
 
-		// Download all files over HTTP.
-		ch := make(chan error)
-		for _, file := range gist.Files {
-			go func(file *GistFile) {
-				defer autonotify()
+			// Write the inline content if it is complete text.
+			if content := file.inlineContent(); content != nil {
 				var err error
-				if err = download(file.RawURL, db.GistFilePath(gistID, file.Filename)); err != nil {
-					err = fmt.Errorf("download: %s: %s", file.RawURL, err)
+				if file.Hash, _, err = writeFile(path, bytes.NewReader(content)); err != nil {
+					err = fmt.Errorf("write: %s: %s", file.Filename, err)
 				}
 				ch <- err
-			}(file)
-		}
+				return
+			}
+
+			// Otherwise download the full content from the raw URL.
+			rawurl, err := db.GitHub.rawURL(file.RawURL)
+			if err != nil {
+				ch <- fmt.Errorf("raw url: %s: %s", file.RawURL, err)
+				return
+			}
 
-		// Check for download errors.
-		for i := 0; i < len(gist.Files); i++ {
-			if err := <-ch; err != nil {
-				return err
+			hash, n, err := download(rawurl, path, db.MaxFileSize)
+			if err == errFileTooLarge {
+				ch <- &SizeLimitError{GistID: gist.ID, Filename: file.Filename, Limit: db.MaxFileSize}
+				return
+			} else if err != nil {
+				ch <- fmt.Errorf("download: %s: %s", rawurl, err)
+				return
 			}
+			file.Hash, file.Size = hash, int(n)
+			ch <- nil
+		}(file, stagedFilePath(dir, i))
+	}
+
+	// Wait for all downloads to finish before checking for errors so
+	// nothing is written to the staging directory after it is removed.
+	var e error
+	for i := 0; i < len(gist.Files); i++ {
+		if err := <-ch; err != nil && e == nil {
+			e = err
 		}
+	}
+	return e
+}
 
-		// Save to the database.
-		if err := tx.SaveGist(gist); err != nil {
-			return fmt.Errorf("save gist: %s", err)
+// readGistConfig reads the configuration file of a gist from the staging
+// directory or the blob store. An invalid file is ignored so that a mistake in
+// the configuration does not prevent the gist from being served.
+func (db *DB) readGistConfig(gist *Gist, dir string) *GistConfig {
+	for i, file := range gist.Files {
+		if file.Filename != GistConfigFilename {
+			continue
 		}
 
+		path := stagedFilePath(dir, i)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			path = db.BlobPath(file.Hash)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			warnf("read %s: %s: %s", GistConfigFilename, gist.ID, err)
+			return nil
+		}
+
+		var config GistConfig
+		if err := json.Unmarshal(b, &config); err != nil {
+			warnf("invalid %s: %s: %s", GistConfigFilename, gist.ID, err)
+			return nil
+		}
+		return &config
+	}
+	return nil
+}
+
+// commitGist moves the staged files of a gist into the blob store and saves
+// the gist. Blobs are immutable so the gist is swapped when tx commits.
+func (db *DB) commitGist(tx *Tx, dir string, gist *Gist) error {
+	for i, file := range gist.Files {
+		path := stagedFilePath(dir, i)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		if err := db.moveBlob(path, file.Hash); err != nil {
+			return fmt.Errorf("move blob: %s", err)
+		}
+	}
+
+	if err := tx.SaveGist(gist); err != nil {
+		return fmt.Errorf("save gist: %s", err)
+	}
+	return nil
+}
+
+// mkstage creates a new staging directory with the given prefix.
+func (db *DB) mkstage(prefix string) (string, error) {
+	root := filepath.Join(db.GistPath, "staging")
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return "", err
+	}
+	return ioutil.TempDir(root, prefix+"-")
+}
+
+// Returns the path of a staged file by its index in the gist.
+func stagedFilePath(dir string, i int) string {
+	return filepath.Join(dir, strconv.Itoa(i))
+}
+
+// Gist returns a gist at a revision. The latest revision is returned if
+// revision is blank. Returns nil if the gist does not exist.
+func (db *DB) Gist(gistID, revision string) (*Gist, error) {
+	if !ValidGistID(gistID) {
+		return nil, nil
+	}
+
+	var g *Gist
+	err := db.View(func(tx *Tx) (err error) {
+		if revision != "" {
+			g, err = tx.GistRevision(gistID, revision)
+		} else {
+			g, err = tx.Gist(gistID)
+		}
+		return
+	})
+	return g, err
+}
+
+// GistExists returns true if the gist has been loaded.
+func (db *DB) GistExists(gistID string) bool {
+	if !ValidGistID(gistID) {
+		return false
+	}
+	var exists bool
+	_ = db.View(func(tx *Tx) error {
+		exists = tx.gists().Get([]byte(gistID)) != nil
 		return nil
 	})
+	return exists
 }
 
-// GistFilePath returns the path for a given gist file.
-func (db *DB) GistFilePath(gistID, filename string) string {
-	return filepath.Join(db.GistPath, gistID, filename)
+// GistFile returns a gist and a file from its manifest. If revision is blank
+// then the latest revision is used. The filename may be a nested path which is
+// mapped using DirSeparator. Returns nil if the gist or file does not exist.
+func (db *DB) GistFile(gistID, revision, filename string) (*Gist, *GistFile, error) {
+	g, err := db.Gist(gistID, revision)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f := g.File(db.gistFilename(filename))
+	if f == nil || !isBlobHash(f.Hash) {
+		return nil, nil, nil
+	}
+	return g, f, nil
+}
+
+// GistFilePath returns the blob path for a gist file. Returns a blank path if
+// the gist or file does not exist. See GistFile.
+func (db *DB) GistFilePath(gistID, revision, filename string) (string, error) {
+	_, f, err := db.GistFile(gistID, revision, filename)
+	if err != nil || f == nil {
+		return "", err
+	}
+	return db.BlobPath(f.Hash), nil
+}
+
+// gistFilename returns the gist filename for a file path. Nested paths are
+// joined with DirSeparator. The filename is only used to look up the manifest
+// so it never resolves to a location outside the blob store.
+func (db *DB) gistFilename(path string) string {
+	if db.DirSeparator == "" || !strings.Contains(path, "/") {
+		return path
+	}
+	return strings.Replace(path, "/", db.DirSeparator, -1)
+}
+
+// Root returns the directory that all gist data is stored within.
+func (db *DB) Root() Root {
+	return Root(db.GistPath)
+}
+
+// BlobPath returns the path to the blob with the given SHA-256 hash.
+// Returns a blank path if the hash is invalid.
+func (db *DB) BlobPath(hash string) string {
+	if !isBlobHash(hash) {
+		return ""
+	}
+	path, _ := db.Root().Path("blobs", hash[:2], hash[2:])
+	return path
+}
+
+// BlobExists returns true if the blob exists in the blob store.
+func (db *DB) BlobExists(hash string) bool {
+	path := db.BlobPath(hash)
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// OpenBlob opens the blob with the given SHA-256 hash for reading.
+func (db *DB) OpenBlob(hash string) (*os.File, error) {
+	if !isBlobHash(hash) {
+		return nil, fmt.Errorf("invalid blob hash: %q", hash)
+	}
+	return db.Root().Open("blobs", hash[:2], hash[2:])
+}
+
+// isBlobHash returns true if s is formatted as a hex-encoded SHA-256 hash.
+func isBlobHash(s string) bool {
+	return len(s) == sha256.Size*2 && isHex(s)
+}
+
+// WriteBlob copies the contents of r into the blob store and returns the
+// SHA-256 hash of the content. Existing blobs are not rewritten.
+func (db *DB) WriteBlob(r io.Reader) (string, error) {
+	dir, err := db.mkstage("blob")
+	if err != nil {
+		return "", fmt.Errorf("staging: %s", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, "blob")
+	hash, _, err := writeFile(path, r)
+	if err != nil {
+		return "", err
+	}
+	return hash, db.moveBlob(path, hash)
+}
+
+// moveBlob moves a file into the blob store. Existing blobs are not rewritten.
+func (db *DB) moveBlob(path, hash string) error {
+	if db.BlobExists(hash) {
+		return nil
+	}
+
+	dst := db.BlobPath(hash)
+	if dst == "" {
+		return fmt.Errorf("invalid blob hash: %q", hash)
+	} else if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	return os.Rename(path, dst)
+}
+
+// errFileTooLarge is returned by download when the file exceeds the limit.
+var errFileTooLarge = errors.New("file too large")
+
+// download retrieves a URL over HTTP GET and writes the response to the path.
+// If limit is greater than zero then larger files return errFileTooLarge.
+// Returns the SHA-256 hash of the content and the number of bytes written.
+func download(url, path string, limit int64) (string, int64, error) {
+	// Retrieve the file over HTTP.
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", 0, fmt.Errorf("get: %s", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	// Check the response code.
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("invalid HTTP status: %d", resp.StatusCode)
+	}
+
+	// Read up to one byte past the limit so larger files can be detected.
+	var r io.Reader = resp.Body
+	if limit > 0 {
+		if resp.ContentLength > limit {
+			return "", 0, errFileTooLarge
+		}
+		r = io.LimitReader(resp.Body, limit+1)
+	}
+
+	// Copy the response to the file and verify that it is complete.
+	hash, n, err := writeFile(path, r)
+	if err != nil {
+		return "", 0, err
+	} else if limit > 0 && n > limit {
+		return "", 0, errFileTooLarge
+	} else if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return "", 0, fmt.Errorf("incomplete file: %d of %d bytes", n, resp.ContentLength)
+	}
+	return hash, n, nil
+}
+
+// writeFile copies r to a new file at path.
+// Returns the SHA-256 hash and the number of bytes written.
+func writeFile(path string, r io.Reader) (hash string, n int64, err error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("create: %s", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	if n, err = io.Copy(io.MultiWriter(f, h), r); err != nil {
+		return "", 0, err
+	} else if err = f.Close(); err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
 }
 
 // Tx represents an application-level transaction.
@@ -140,7 +623,11 @@ func (tx *Tx) meta() *bolt.Bucket  { return tx.Bucket([]byte("meta")) }
 func (tx *Tx) gists() *bolt.Bucket { return tx.Bucket([]byte("gists")) }
 func (tx *Tx) users() *bolt.Bucket { return tx.Bucket([]byte("users")) }
 
+func (tx *Tx) gistRevisions() *bolt.Bucket { return tx.Bucket([]byte("gistRevisions")) }
+func (tx *Tx) gistSyncs() *bolt.Bucket     { return tx.Bucket([]byte("gistSyncs")) }
+
 func (tx *Tx) gistsByUserID() *bolt.Bucket { return tx.Bucket([]byte("gistsByUserID")) }
+func (tx *Tx) gistLists() *bolt.Bucket     { return tx.Bucket([]byte("gistLists")) }
 
 // Gist retrieves a gist from the database by ID.
 func (tx *Tx) Gist(id string) (g *Gist, err error) {
@@ -164,9 +651,59 @@ func (tx *Tx) SaveGist(g *Gist) error {
 		return err
 	}
 
+	// Save a copy to the revision history, if the revision is known.
+	if g.Revision != "" {
+		if err := tx.gistRevisions().Put(revisionKey(g.ID, g.Revision), b); err != nil {
+			return err
+		}
+	}
+
 	return tx.gists().Put([]byte(g.ID), b)
 }
 
+// GistRevision retrieves a single revision of a gist from the database.
+func (tx *Tx) GistRevision(id, revision string) (g *Gist, err error) {
+	if v := tx.gistRevisions().Get(revisionKey(id, revision)); v != nil {
+		err = json.Unmarshal(v, &g)
+	}
+	return
+}
+
+// GistRevisions retrieves all saved revisions of a gist, newest first.
+func (tx *Tx) GistRevisions(id string) ([]*Gist, error) {
+	c := tx.gistRevisions().Cursor()
+	seek := revisionKey(id, "")
+
+	var a []*Gist
+	for k, v := c.Seek(seek); bytes.HasPrefix(k, seek); k, v = c.Next() {
+		var g *Gist
+		if err := json.Unmarshal(v, &g); err != nil {
+			return nil, err
+		}
+		a = append(a, g)
+	}
+	sort.Sort(gistsByUpdatedAt(a))
+	return a, nil
+}
+
+// GistList retrieves a cached page of a user's gists on GitHub.
+func (tx *Tx) GistList(userID, page int) (l *GistList, err error) {
+	if v := tx.gistLists().Get(gistListKey(userID, page)); v != nil {
+		err = json.Unmarshal(v, &l)
+	}
+	return
+}
+
+// SaveGistList caches a page of a user's gists on GitHub.
+func (tx *Tx) SaveGistList(userID, page int, l *GistList) error {
+	assert(l != nil, "nil gist list")
+	b, err := json.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("marshal gist list: %s", err)
+	}
+	return tx.gistLists().Put(gistListKey(userID, page), b)
+}
+
 // GistsByUserID retrieves a list of gists owned by a user.
 func (tx *Tx) GistsByUserID(userID int) ([]*Gist, error) {
 	c := tx.gistsByUserID().Cursor()
@@ -183,6 +720,34 @@ func (tx *Tx) GistsByUserID(userID int) ([]*Gist, error) {
 	return a, nil
 }
 
+// hostedGists returns a reference to every gist in the user index.
+func (tx *Tx) hostedGists() ([]gistRef, error) {
+	var a []gistRef
+	err := tx.gistsByUserID().ForEach(func(k, _ []byte) error {
+		a = append(a, gistRef{userID: int(btoi64(k[:8])), gistID: string(k[8:])})
+		return nil
+	})
+	return a, err
+}
+
+// SyncStatus retrieves the last background sync result for a gist.
+func (tx *Tx) SyncStatus(gistID string) (s *SyncStatus, err error) {
+	if v := tx.gistSyncs().Get([]byte(gistID)); v != nil {
+		err = json.Unmarshal(v, &s)
+	}
+	return
+}
+
+// SaveSyncStatus stores the background sync result for a gist.
+func (tx *Tx) SaveSyncStatus(gistID string, s *SyncStatus) error {
+	assert(s != nil, "nil sync status")
+	b, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshal sync status: %s", err)
+	}
+	return tx.gistSyncs().Put([]byte(gistID), b)
+}
+
 // User retrieves an user from the database by ID.
 func (tx *Tx) User(id int) (u *User, err error) {
 	if v := tx.users().Get(i64tob(int64(id))); v != nil {
@@ -222,45 +787,78 @@ func (tx *Tx) GenerateSecretIfNotExists() error {
 	return tx.meta().Put([]byte("secret"), value)
 }
 
-// download retrieves a URL over HTTP GET and writes the response to the path.
-func download(url, path string) error {
-	// Create the parent directory.
-	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
-		return err
-	}
+// flightGroup deduplicates concurrent calls that share the same key.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
 
-	// Retrieve the file over HTTP.
-	resp, err := http.Get(url)
-	if err != nil {
-		return fmt.Errorf("get: %s", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
+// flightCall represents an in-flight call within a flightGroup.
+type flightCall struct {
+	wg  sync.WaitGroup
+	err error
+}
 
-	// Check the response code.
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("invalid HTTP status: %d", resp.StatusCode)
+// Do executes fn unless a call with the same key is already in-flight.
+// In that case it waits for the in-flight call and returns its error.
+func (g *flightGroup) Do(key string, fn func() error) error {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
 	}
-
-	// Open the file to write to.
-	f, err := os.Create(path)
-	if err != nil {
-		return fmt.Errorf("create: %s", err)
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.err
 	}
-	defer func() { _ = f.Close() }()
+	c := &flightCall{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	// Remove the call once complete so later calls run again. This is
+	// deferred so waiters are released with an error even if fn panics.
+	completed := false
+	defer func() {
+		if !completed {
+			c.err = fmt.Errorf("panic during call: %s", key)
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.err = fn()
+	completed = true
+	return c.err
+}
 
-	// Copy from the response to the file.
-	// Note: This is not perfect as a partial file can be read but this is
-	// not the record of authority so we can always retry if there's a problem.
-	if _, err := io.Copy(f, resp.Body); err != nil {
-		return err
-	}
+// Returns the key used to store a gist revision.
+func revisionKey(id, revision string) []byte {
+	return []byte(id + "/" + revision)
+}
 
-	return nil
+// gistListKey returns the key of a cached page of a user's gists.
+func gistListKey(userID, page int) []byte {
+	return append(i64tob(int64(userID)), i64tob(int64(page))...)
 }
 
+// gistsByUpdatedAt sorts gists by update time, newest first.
+type gistsByUpdatedAt []*Gist
+
+func (a gistsByUpdatedAt) Len() int           { return len(a) }
+func (a gistsByUpdatedAt) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a gistsByUpdatedAt) Less(i, j int) bool { return a[i].UpdatedAt.After(a[j].UpdatedAt) }
+
 // Converts an integer to a big-endian encoded byte slice.
 func i64tob(v int64) []byte {
 	var b = make([]byte, 8)
 	binary.BigEndian.PutUint64(b, uint64(v))
 	return b
 }
+
+// Converts a big-endian encoded byte slice to an integer.
+func btoi64(b []byte) int64 {
+	return int64(binary.BigEndian.Uint64(b))
+}
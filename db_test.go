@@ -1,8 +1,19 @@
 package gist_test
 
 import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -51,6 +62,541 @@ func TestTx_SaveGist(t *testing.T) {
 	}))
 }
 
+// Ensure that each revision of a gist is kept in the database.
+func TestTx_GistRevisions(t *testing.T) {
+	db := NewTestDB()
+	defer db.Close()
+
+	rev1 := &gist.Gist{ID: "xxx", UserID: 1000, Revision: "aaa", Description: "v1", UpdatedAt: parsetime("2000-01-01T00:00:00Z")}
+	rev2 := &gist.Gist{ID: "xxx", UserID: 1000, Revision: "bbb", Description: "v2", UpdatedAt: parsetime("2000-01-02T00:00:00Z")}
+	other := &gist.Gist{ID: "yyy", UserID: 1000, Revision: "ccc", Description: "other"}
+
+	ok(t, db.Update(func(tx *gist.Tx) error {
+		ok(t, tx.SaveGist(rev1))
+		ok(t, tx.SaveGist(rev2))
+		ok(t, tx.SaveGist(other))
+		return nil
+	}))
+
+	ok(t, db.View(func(tx *gist.Tx) error {
+		// The latest revision should be the current gist.
+		g, _ := tx.Gist("xxx")
+		equals(t, rev2, g)
+
+		// Both revisions should be available, newest first.
+		a, err := tx.GistRevisions("xxx")
+		ok(t, err)
+		equals(t, []*gist.Gist{rev2, rev1}, a)
+
+		// Individual revisions should be retrievable.
+		g, _ = tx.GistRevision("xxx", "aaa")
+		equals(t, rev1, g)
+		g, _ = tx.GistRevision("xxx", "zzz")
+		assert(t, g == nil, "expected nil revision")
+		return nil
+	}))
+}
+
+// Ensure that the gist configuration file is read when a gist is loaded.
+func TestDB_LoadGist_Config(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"cache": {"maxAge": 300, "immutable": true}}`))
+	}))
+	defer s.Close()
+
+	db := NewTestDB()
+	defer db.Close()
+	db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"})
+
+	client := &MockGitHubClient{}
+	client.GistFunc = func(id string) (*gist.Gist, error) {
+		return &gist.Gist{ID: "xxx", UserID: 1000, Files: []*gist.GistFile{
+			{Filename: gist.GistConfigFilename, RawURL: s.URL + "/.gistconfig"},
+		}}, nil
+	}
+	db.NewGitHubClient = func(_ string) gist.GitHubClient { return client }
+	ok(t, db.LoadGist(1000, "xxx"))
+
+	ok(t, db.View(func(tx *gist.Tx) error {
+		g, _ := tx.Gist("xxx")
+		equals(t, &gist.GistConfig{Cache: &gist.CachePolicy{MaxAge: 300, Immutable: true}}, g.Config)
+		return nil
+	}))
+}
+
+// Ensure that nested paths are mapped to gist filenames using the separator.
+func TestDB_GistFilePath_Nested(t *testing.T) {
+	db := NewTestDB()
+	defer db.Close()
+
+	hash := MustWriteBlob(db.DB, "body {}")
+	ok(t, db.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "xxx", Files: []*gist.GistFile{
+			{Filename: "css__style.css", Hash: hash},
+		}})
+	}))
+
+	// Nested paths are disabled without a separator.
+	path, err := db.GistFilePath("xxx", "", "css/style.css")
+	ok(t, err)
+	equals(t, "", path)
+
+	// The nested path and the gist filename resolve to the same blob.
+	db.DirSeparator = "__"
+	path, err = db.GistFilePath("xxx", "", "css/style.css")
+	ok(t, err)
+	equals(t, db.BlobPath(hash), path)
+	path, err = db.GistFilePath("xxx", "", "css__style.css")
+	ok(t, err)
+	equals(t, db.BlobPath(hash), path)
+
+	// Unknown paths do not resolve.
+	path, err = db.GistFilePath("xxx", "", "css/../style.css")
+	ok(t, err)
+	equals(t, "", path)
+}
+
+// Ensure that identical files are stored once and unchanged files are not
+// downloaded again on reload.
+func TestDB_LoadGist_Blobs(t *testing.T) {
+	// Run mock GitHub raw server and count file requests.
+	var n int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		w.Write([]byte(`same content`))
+	}))
+	defer s.Close()
+
+	db := NewTestDB()
+	defer db.Close()
+	db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"})
+
+	// Return two files with the same content.
+	client := &MockGitHubClient{}
+	client.GistFunc = func(id string) (*gist.Gist, error) {
+		return &gist.Gist{ID: "xxx", UserID: 1000, Files: []*gist.GistFile{
+			{Filename: "a.txt", RawURL: s.URL + "/raw/1/a.txt"},
+			{Filename: "b.txt", RawURL: s.URL + "/raw/2/b.txt"},
+		}}, nil
+	}
+	db.NewGitHubClient = func(_ string) gist.GitHubClient { return client }
+
+	// Load gist and verify both files reference the same blob.
+	ok(t, db.LoadGist(1000, "xxx"))
+	equals(t, int32(2), atomic.LoadInt32(&n))
+	a, _ := db.GistFilePath("xxx", "", "a.txt")
+	b, _ := db.GistFilePath("xxx", "", "b.txt")
+	equals(t, a, b)
+	assert(t, strings.HasPrefix(a, filepath.Join(db.GistPath, "blobs")), "unexpected blob path: %s", a)
+
+	// Reload and verify that nothing is downloaded again.
+	ok(t, db.LoadGist(1000, "xxx"))
+	equals(t, int32(2), atomic.LoadInt32(&n))
+}
+
+// Ensure that a failed download leaves the previous gist intact and removes
+// the staged files.
+func TestDB_LoadGist_Rollback(t *testing.T) {
+	// Run mock GitHub raw server that fails for one file.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/raw/3/b.txt" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer s.Close()
+
+	db := NewTestDB()
+	defer db.Close()
+	db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"})
+
+	// Return a successful version first and then a failing version.
+	files := []*gist.GistFile{
+		{Filename: "a.txt", RawURL: s.URL + "/raw/1/a.txt"},
+		{Filename: "b.txt", RawURL: s.URL + "/raw/2/b.txt"},
+	}
+	client := &MockGitHubClient{}
+	client.GistFunc = func(id string) (*gist.Gist, error) {
+		return &gist.Gist{ID: "xxx", UserID: 1000, Files: files}, nil
+	}
+	db.NewGitHubClient = func(_ string) gist.GitHubClient { return client }
+	ok(t, db.LoadGist(1000, "xxx"))
+
+	files = []*gist.GistFile{
+		{Filename: "a.txt", RawURL: s.URL + "/raw/4/a.txt"},
+		{Filename: "b.txt", RawURL: s.URL + "/raw/3/b.txt"},
+	}
+	assert(t, db.LoadGist(1000, "xxx") != nil, "expected error")
+
+	// The previous version should still be served.
+	path, _ := db.GistFilePath("xxx", "", "a.txt")
+	content, _ := ioutil.ReadFile(path)
+	equals(t, "/raw/1/a.txt", string(content))
+
+	// The successfully downloaded file should not be in the blob store.
+	equals(t, false, db.BlobExists(fmt.Sprintf("%x", sha256.Sum256([]byte("/raw/4/a.txt")))))
+
+	// The staging directory should be empty.
+	fis, _ := ioutil.ReadDir(filepath.Join(db.GistPath, "staging"))
+	equals(t, 0, len(fis))
+}
+
+// Ensure that reloads of different gists do not block each other.
+func TestDB_LoadGist_Concurrent(t *testing.T) {
+	// Run mock GitHub raw server. The file for "aaa" does not finish
+	// downloading until the gist "bbb" has been saved.
+	var once sync.Once
+	started, done := make(chan struct{}), make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/aaa/index.html" {
+			once.Do(func() { close(started) })
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Error("timeout: reloads are serialized")
+			}
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer s.Close()
+
+	db := NewTestDB()
+	defer db.Close()
+	db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"})
+
+	client := &MockGitHubClient{}
+	client.GistFunc = func(id string) (*gist.Gist, error) {
+		return &gist.Gist{ID: id, UserID: 1000, Files: []*gist.GistFile{
+			{Filename: "index.html", RawURL: s.URL + "/" + id + "/index.html"},
+		}}, nil
+	}
+	db.NewGitHubClient = func(_ string) gist.GitHubClient { return client }
+
+	// Start the slow reload and wait until it is downloading before
+	// reloading the other gist.
+	errs := make(chan error)
+	go func() { errs <- db.LoadGist(1000, "aaa") }()
+	select {
+	case <-started:
+	case err := <-errs:
+		t.Fatalf("reload finished before download: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout: download not started")
+	}
+	ok(t, db.LoadGist(1000, "bbb"))
+	close(done)
+	ok(t, <-errs)
+
+	// Both gists should be saved.
+	ok(t, db.View(func(tx *gist.Tx) error {
+		a, _ := tx.Gist("aaa")
+		b, _ := tx.Gist("bbb")
+		assert(t, a != nil && b != nil, "expected gists")
+		return nil
+	}))
+}
+
+// Ensure that concurrent reloads of the same gist share a single refresh.
+func TestDB_LoadGist_Deduplicate(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html></html>`))
+	}))
+	defer s.Close()
+
+	db := NewTestDB()
+	defer db.Close()
+	db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"})
+
+	// Block the GitHub request until all reloads have started.
+	var n int32
+	started, release := make(chan struct{}), make(chan struct{})
+	client := &MockGitHubClient{}
+	client.GistFunc = func(id string) (*gist.Gist, error) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			close(started)
+		}
+		<-release
+		return nil, errors.New("marker")
+	}
+	db.NewGitHubClient = func(_ string) gist.GitHubClient { return client }
+
+	// Start multiple reloads and wait for them to join the first one.
+	errs := make(chan error)
+	for i := 0; i < 5; i++ {
+		go func() { errs <- db.LoadGist(1000, "xxx") }()
+	}
+	<-started
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	// Every caller should receive the shared result.
+	for i := 0; i < 5; i++ {
+		err := <-errs
+		assert(t, err != nil && strings.Contains(err.Error(), "marker"), "unexpected error: %v", err)
+	}
+	equals(t, int32(1), atomic.LoadInt32(&n))
+}
+
+// Ensure that a panic during a reload releases the waiting callers.
+func TestDB_LoadGist_Deduplicate_Panic(t *testing.T) {
+	db := NewTestDB()
+	defer db.Close()
+	db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"})
+
+	// Panic in the first request once a second reload is waiting on it.
+	var n int32
+	started, release := make(chan struct{}), make(chan struct{})
+	client := &MockGitHubClient{}
+	client.GistFunc = func(id string) (*gist.Gist, error) {
+		if atomic.AddInt32(&n, 1) == 1 {
+			close(started)
+			<-release
+			panic("marker")
+		}
+		return nil, errors.New("second call")
+	}
+	db.NewGitHubClient = func(_ string) gist.GitHubClient { return client }
+
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		db.LoadGist(1000, "xxx")
+	}()
+	<-started
+
+	errs := make(chan error)
+	go func() { errs <- db.LoadGist(1000, "xxx") }()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	equals(t, "marker", <-panicked)
+	err := <-errs
+	assert(t, err != nil && strings.Contains(err.Error(), "panic"), "unexpected error: %v", err)
+
+	// Later calls run again.
+	err = db.LoadGist(1000, "xxx")
+	assert(t, err != nil && strings.Contains(err.Error(), "second call"), "unexpected error: %v", err)
+}
+
+// Ensure that a gist with cache validators is only refreshed if it changed.
+func TestDB_LoadGist_NotModified(t *testing.T) {
+	db := NewTestDB()
+	defer db.Close()
+	db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"})
+
+	// Save a previously retrieved gist.
+	prev := &gist.Gist{ID: "xxx", UserID: 1000, Description: "v1", ETag: `"abc"`, LastModified: "Mon, 02 Jan 2006 15:04:05 GMT"}
+	ok(t, db.Update(func(tx *gist.Tx) error { return tx.SaveGist(prev) }))
+
+	// Return not modified if the validators are passed in.
+	client := &MockGitHubClient{}
+	client.GistIfModifiedFunc = func(id, etag, lastModified string) (*gist.Gist, error) {
+		equals(t, `"abc"`, etag)
+		equals(t, "Mon, 02 Jan 2006 15:04:05 GMT", lastModified)
+		return nil, gist.ErrNotModified
+	}
+	db.NewGitHubClient = func(_ string) gist.GitHubClient { return client }
+	ok(t, db.LoadGist(1000, "xxx"))
+
+	// The gist should be unchanged other than its sync time.
+	ok(t, db.View(func(tx *gist.Tx) error {
+		g, _ := tx.Gist("xxx")
+		assert(t, !g.SyncedAt.IsZero(), "expected sync time")
+		g.SyncedAt = prev.SyncedAt
+		equals(t, prev, g)
+		return nil
+	}))
+}
+
+// Ensure that raw files are downloaded from the configured raw host.
+func TestDB_LoadGist_RawHost(t *testing.T) {
+	// Run mock GitHub Enterprise raw server.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equals(t, "/raw/1/index.html", r.URL.Path)
+		w.Write([]byte(`<html></html>`))
+	}))
+	defer s.Close()
+	u, _ := url.Parse(s.URL)
+
+	db := NewTestDB()
+	defer db.Close()
+	db.GitHub = &gist.GitHubConfig{RawHost: u.Host}
+	db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"})
+
+	// Return a raw URL with a host that cannot be reached.
+	client := &MockGitHubClient{}
+	client.GistFunc = func(id string) (*gist.Gist, error) {
+		return &gist.Gist{ID: "xxx", UserID: 1000, Files: []*gist.GistFile{
+			{Filename: "index.html", RawURL: "http://raw.invalid/raw/1/index.html"},
+		}}, nil
+	}
+	db.NewGitHubClient = func(_ string) gist.GitHubClient { return client }
+	ok(t, db.LoadGist(1000, "xxx"))
+
+	path, _ := db.GistFilePath("xxx", "", "index.html")
+	content, _ := ioutil.ReadFile(path)
+	equals(t, `<html></html>`, string(content))
+}
+
+// Ensure that inline content is used and truncated files are downloaded in full.
+func TestDB_LoadGist_Truncated(t *testing.T) {
+	// Run mock GitHub API and raw server.
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gists/xxx":
+			fmt.Fprintf(w, `{"id": "xxx","owner": {"id":1000},"files": {`+
+				`"a.txt": {"filename": "a.txt","type": "text/plain","raw_url": "%[1]s/raw/a.txt","size": 6,"content": "inline"},`+
+				`"b.txt": {"filename": "b.txt","raw_url": "%[1]s/raw/b.txt","size": 11,"truncated": true,"content": "part"}}}`, s.URL)
+		case "/raw/b.txt":
+			w.Write([]byte("full content"))
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	db := NewTestDB()
+	defer db.Close()
+	db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"})
+	db.NewGitHubClient = func(token string) gist.GitHubClient {
+		c := gist.NewGitHubClient(token)
+		c.SetBaseURL(s.URL)
+		return c
+	}
+	ok(t, db.LoadGist(1000, "xxx"))
+
+	path, _ := db.GistFilePath("xxx", "", "a.txt")
+	content, _ := ioutil.ReadFile(path)
+	equals(t, "inline", string(content))
+
+	path, _ = db.GistFilePath("xxx", "", "b.txt")
+	content, _ = ioutil.ReadFile(path)
+	equals(t, "full content", string(content))
+
+	// Verify the size is updated from the downloaded content.
+	ok(t, db.View(func(tx *gist.Tx) error {
+		g, _ := tx.Gist("xxx")
+		assert(t, !g.SyncedAt.IsZero(), "expected sync time")
+		equals(t, 12, g.File("b.txt").Size)
+		return nil
+	}))
+}
+
+// Ensure that binary files are downloaded rather than taken from the API.
+func TestDB_LoadGist_Binary(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gists/xxx":
+			// The API returns a lossy text version of binary content.
+			fmt.Fprintf(w, `{"id": "xxx","owner": {"id":1000},"files": {`+
+				`"logo.png": {"filename": "logo.png","type": "image/png","raw_url": "%[1]s/raw/logo.png","size": %[2]d,"content": "\ufffdPNG\r\n\u001a\n"},`+
+				`"font.woff": {"filename": "font.woff","type": "font/woff","encoding": "base64","raw_url": "%[1]s/raw/font.woff","size": 4,"content": "d09GMg=="}}}`, s.URL, len(png))
+		case "/raw/logo.png":
+			w.Write([]byte(png))
+		case "/raw/font.woff":
+			w.Write([]byte("wOF2"))
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer s.Close()
+
+	db := NewTestDB()
+	defer db.Close()
+	db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"})
+	db.NewGitHubClient = func(token string) gist.GitHubClient {
+		c := gist.NewGitHubClient(token)
+		c.SetBaseURL(s.URL)
+		return c
+	}
+	ok(t, db.LoadGist(1000, "xxx"))
+	equals(t, png, MustReadGistFile(db, "xxx", "", "logo.png"))
+	equals(t, "wOF2", MustReadGistFile(db, "xxx", "", "font.woff"))
+}
+
+// Ensure that gists over the size limits are not loaded.
+func TestDB_LoadGist_SizeLimit(t *testing.T) {
+	// Run mock raw server that returns more content than reported.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer s.Close()
+
+	db := NewTestDB()
+	defer db.Close()
+	db.MaxFileSize, db.MaxGistSize = 50, 80
+	db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"})
+
+	var files []*gist.GistFile
+	client := &MockGitHubClient{}
+	client.GistFunc = func(id string) (*gist.Gist, error) {
+		return &gist.Gist{ID: "xxx", UserID: 1000, Files: files}, nil
+	}
+	db.NewGitHubClient = func(_ string) gist.GitHubClient { return client }
+
+	// A file reported over the limit is rejected before downloading.
+	files = []*gist.GistFile{{Filename: "a.txt", Size: 51, RawURL: s.URL + "/a.txt"}}
+	err := db.LoadGist(1000, "xxx")
+	equals(t, &gist.SizeLimitError{GistID: "xxx", Filename: "a.txt", Limit: 50}, err)
+
+	// Files reported over the gist limit are rejected before downloading.
+	files = []*gist.GistFile{
+		{Filename: "a.txt", Size: 40, RawURL: s.URL + "/a.txt"},
+		{Filename: "b.txt", Size: 41, RawURL: s.URL + "/b.txt"},
+	}
+	err = db.LoadGist(1000, "xxx")
+	equals(t, &gist.SizeLimitError{GistID: "xxx", Limit: 80}, err)
+
+	// A file with more content than reported is rejected while downloading.
+	files = []*gist.GistFile{{Filename: "a.txt", Size: 10, RawURL: s.URL + "/a.txt"}}
+	err = db.LoadGist(1000, "xxx")
+	equals(t, &gist.SizeLimitError{GistID: "xxx", Filename: "a.txt", Limit: 50}, err)
+
+	// Verify that nothing was saved.
+	ok(t, db.View(func(tx *gist.Tx) error {
+		g, _ := tx.Gist("xxx")
+		equals(t, (*gist.Gist)(nil), g)
+		return nil
+	}))
+}
+
+// Ensure that hostile gist IDs and filenames are refused when loading.
+func TestDB_LoadGist_ErrInvalid(t *testing.T) {
+	var tests = []struct {
+		gistID string
+		gist   *gist.Gist
+		err    string
+	}{
+		{gistID: "../db", err: `invalid gist id: "../db"`},
+		{gistID: "xxx/../../db", err: `invalid gist id: "xxx/../../db"`},
+		{gistID: "xxx", gist: &gist.Gist{ID: "yyy"}, err: `gist id mismatch: "yyy" != "xxx"`},
+		{gistID: "xxx", gist: &gist.Gist{ID: "xxx", Files: []*gist.GistFile{{Filename: "../../db"}}}, err: `invalid filename: "../../db"`},
+		{gistID: "xxx", gist: &gist.Gist{ID: "xxx", Files: []*gist.GistFile{{Filename: ".."}}}, err: `invalid filename: ".."`},
+		{gistID: "xxx", gist: &gist.Gist{ID: "xxx", Files: []*gist.GistFile{{Filename: "a\\b"}}}, err: `invalid filename: "a\\b"`},
+		{gistID: "xxx", gist: &gist.Gist{ID: "xxx", Files: []*gist.GistFile{{Filename: ""}}}, err: `invalid filename: ""`},
+		{gistID: "xxx", gist: &gist.Gist{ID: "xxx", Files: []*gist.GistFile{{Filename: "a.txt"}, {Filename: "a.txt"}}}, err: `duplicate filename: "a.txt"`},
+	}
+	for i, tt := range tests {
+		db := NewTestDB()
+		db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"})
+		client := &MockGitHubClient{}
+		client.GistFunc = func(id string) (*gist.Gist, error) { return tt.gist, nil }
+		db.NewGitHubClient = func(_ string) gist.GitHubClient { return client }
+
+		err := db.LoadGist(1000, tt.gistID)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%d. error: exp: %s, got: %v", i, tt.err, err)
+		}
+		db.Close()
+	}
+}
+
 // Ensure that a user can be persisted to the database.
 func TestTx_SaveUser(t *testing.T) {
 	db := NewTestDB()
@@ -75,6 +621,7 @@ type TestDB struct {
 
 func NewTestDB() *TestDB {
 	db := &TestDB{DB: &gist.DB{}}
+	db.GistPath = tempfile()
 	if err := db.Open(tempfile(), 0600); err != nil {
 		log.Fatal("open: ", err)
 	}
@@ -83,5 +630,22 @@ func NewTestDB() *TestDB {
 
 func (db *TestDB) Close() error {
 	defer os.RemoveAll(db.Path())
+	defer os.RemoveAll(db.GistPath)
 	return db.DB.Close()
 }
+
+// MustSaveUser saves a user to the database. Panic on error.
+func (db *TestDB) MustSaveUser(u *gist.User) {
+	if err := db.Update(func(tx *gist.Tx) error { return tx.SaveUser(u) }); err != nil {
+		panic(err)
+	}
+}
+
+// MustWriteBlob writes a string to the blob store and returns its hash. Panic on error.
+func MustWriteBlob(db *gist.DB, s string) string {
+	hash, err := db.WriteBlob(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return hash
+}
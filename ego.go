@@ -5,7 +5,7 @@ import (
 "time"
 )
 //line dashboard.ego:1
- func (t *tmpl) Dashboard(w io.Writer, hosted, recent []*Gist) error  {
+ func (t *tmpl) Dashboard(w io.Writer, hosted, recent []*Gist, page, next int) error  {
 //line dashboard.ego:2
 if _, err := fmt.Fprintf(w, "\n\n"); err != nil { return err }
 //line dashboard.ego:4
@@ -253,14 +253,66 @@ if _, err := fmt.Fprintf(w, "</table>\n      "); err != nil { return err }
 //line dashboard.ego:93
  } 
 //line dashboard.ego:94
-if _, err := fmt.Fprintf(w, "\n\n    "); err != nil { return err }
+if _, err := fmt.Fprintf(w, "\n\n      "); err != nil { return err }
 //line dashboard.ego:95
+ if page > 1 || next > 0 { 
+//line dashboard.ego:96
+if _, err := fmt.Fprintf(w, "\n        "); err != nil { return err }
+//line dashboard.ego:96
+if _, err := fmt.Fprintf(w, "<ul class=\"pager\">\n          "); err != nil { return err }
+//line dashboard.ego:97
+ if page > 1 { 
+//line dashboard.ego:98
+if _, err := fmt.Fprintf(w, "\n            "); err != nil { return err }
+//line dashboard.ego:98
+if _, err := fmt.Fprintf(w, "<li class=\"previous\">"); err != nil { return err }
+//line dashboard.ego:98
+if _, err := fmt.Fprintf(w, "<a href=\"/_/dashboard?page="); err != nil { return err }
+//line dashboard.ego:98
+if _, err := fmt.Fprintf(w, "%v",  page-1 ); err != nil { return err }
+//line dashboard.ego:98
+if _, err := fmt.Fprintf(w, "\">&larr; Newer"); err != nil { return err }
+//line dashboard.ego:98
+if _, err := fmt.Fprintf(w, "</a>"); err != nil { return err }
+//line dashboard.ego:98
+if _, err := fmt.Fprintf(w, "</li>\n          "); err != nil { return err }
+//line dashboard.ego:99
+ } 
+//line dashboard.ego:100
+if _, err := fmt.Fprintf(w, "\n          "); err != nil { return err }
+//line dashboard.ego:100
+ if next > 0 { 
+//line dashboard.ego:101
+if _, err := fmt.Fprintf(w, "\n            "); err != nil { return err }
+//line dashboard.ego:101
+if _, err := fmt.Fprintf(w, "<li class=\"next\">"); err != nil { return err }
+//line dashboard.ego:101
+if _, err := fmt.Fprintf(w, "<a href=\"/_/dashboard?page="); err != nil { return err }
+//line dashboard.ego:101
+if _, err := fmt.Fprintf(w, "%v",  next ); err != nil { return err }
+//line dashboard.ego:101
+if _, err := fmt.Fprintf(w, "\">Older &rarr;"); err != nil { return err }
+//line dashboard.ego:101
+if _, err := fmt.Fprintf(w, "</a>"); err != nil { return err }
+//line dashboard.ego:101
+if _, err := fmt.Fprintf(w, "</li>\n          "); err != nil { return err }
+//line dashboard.ego:102
+ } 
+//line dashboard.ego:103
+if _, err := fmt.Fprintf(w, "\n        "); err != nil { return err }
+//line dashboard.ego:103
+if _, err := fmt.Fprintf(w, "</ul>\n      "); err != nil { return err }
+//line dashboard.ego:104
+ } 
+//line dashboard.ego:105
+if _, err := fmt.Fprintf(w, "\n\n    "); err != nil { return err }
+//line dashboard.ego:106
 if _, err := fmt.Fprintf(w, "</div> "); err != nil { return err }
-//line dashboard.ego:95
+//line dashboard.ego:106
 if _, err := fmt.Fprintf(w, "<!-- /container -->\n  "); err != nil { return err }
-//line dashboard.ego:96
+//line dashboard.ego:107
 if _, err := fmt.Fprintf(w, "</body>\n"); err != nil { return err }
-//line dashboard.ego:97
+//line dashboard.ego:108
 if _, err := fmt.Fprintf(w, "</html>\n\n"); err != nil { return err }
 return nil
 }
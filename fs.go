@@ -0,0 +1,55 @@
+package gist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPathEscapesRoot is returned when a path resolves outside of its root.
+var ErrPathEscapesRoot = errors.New("path escapes root")
+
+// Root represents a directory on disk that paths are resolved within.
+// Paths that would resolve outside of the directory are refused.
+type Root string
+
+// Path joins the path elements onto the root. Returns ErrPathEscapesRoot if
+// an element is absolute or the joined path is outside of the root.
+func (r Root) Path(elem ...string) (string, error) {
+	for _, e := range elem {
+		if filepath.IsAbs(e) || strings.ContainsRune(e, 0) {
+			return "", ErrPathEscapesRoot
+		}
+	}
+
+	rel := filepath.Join(elem...)
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrPathEscapesRoot
+	}
+	return filepath.Join(string(r), rel), nil
+}
+
+// Open opens a file for reading within the root. Symlinks are resolved so a
+// link pointing outside of the root returns ErrPathEscapesRoot.
+func (r Root) Open(elem ...string) (*os.File, error) {
+	path, err := r.Path(elem...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve symlinks in both the root and the path before comparing.
+	root, err := filepath.EvalSymlinks(string(r))
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	if rel, err := filepath.Rel(root, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, ErrPathEscapesRoot
+	}
+
+	return os.Open(resolved)
+}
@@ -0,0 +1,62 @@
+package gist_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/benbjohnson/gist"
+)
+
+// Ensure that paths cannot be joined outside of the root.
+func TestRoot_Path(t *testing.T) {
+	root := gist.Root("/data")
+	var tests = []struct {
+		elem []string
+		path string
+		err  error
+	}{
+		{elem: []string{"blobs", "ab", "cdef"}, path: "/data/blobs/ab/cdef"},
+		{elem: []string{"a/../b"}, path: "/data/b"},
+		{elem: []string{}, path: "/data"},
+		{elem: []string{".."}, err: gist.ErrPathEscapesRoot},
+		{elem: []string{"../etc/passwd"}, err: gist.ErrPathEscapesRoot},
+		{elem: []string{"a", "..", "..", "etc"}, err: gist.ErrPathEscapesRoot},
+		{elem: []string{"repos", "../../x.git"}, err: gist.ErrPathEscapesRoot},
+		{elem: []string{"/etc/passwd"}, err: gist.ErrPathEscapesRoot},
+		{elem: []string{"a\x00b"}, err: gist.ErrPathEscapesRoot},
+	}
+	for i, tt := range tests {
+		path, err := root.Path(tt.elem...)
+		if tt.err != err {
+			t.Errorf("%d. %q: error: exp: %v, got: %v", i, tt.elem, tt.err, err)
+		} else if tt.path != path {
+			t.Errorf("%d. %q: path: exp: %s, got: %s", i, tt.elem, tt.path, path)
+		}
+	}
+}
+
+// Ensure that files can be opened within the root but not through a symlink
+// that points outside of it.
+func TestRoot_Open(t *testing.T) {
+	dir := tempfile()
+	defer os.RemoveAll(dir)
+	ok(t, os.MkdirAll(filepath.Join(dir, "root"), 0700))
+	ok(t, ioutil.WriteFile(filepath.Join(dir, "root", "inside"), []byte("inside"), 0600))
+	ok(t, ioutil.WriteFile(filepath.Join(dir, "outside"), []byte("outside"), 0600))
+	ok(t, os.Symlink(filepath.Join(dir, "outside"), filepath.Join(dir, "root", "link")))
+	root := gist.Root(filepath.Join(dir, "root"))
+
+	f, err := root.Open("inside")
+	ok(t, err)
+	b, _ := ioutil.ReadAll(f)
+	f.Close()
+	equals(t, "inside", string(b))
+
+	_, err = root.Open("link")
+	equals(t, gist.ErrPathEscapesRoot, err)
+
+	_, err = root.Open("..", "outside")
+	equals(t, gist.ErrPathEscapesRoot, err)
+}
@@ -2,8 +2,12 @@ package gist
 
 import (
 	"fmt"
+	"mime"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/bugsnag/bugsnag-go"
 )
@@ -15,15 +19,208 @@ type Gist struct {
 	Description string      `json:"description"`
 	Public      bool        `json:"public"`
 	URL         string      `json:"url"`
+	GitPullURL  string      `json:"gitPullURL,omitempty"`
+	Revision    string      `json:"revision,omitempty"`
 	Files       []*GistFile `json:"files"`
 	CreatedAt   time.Time   `json:"createdAt"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+
+	// Time the content was last retrieved from GitHub.
+	SyncedAt time.Time `json:"syncedAt"`
+
+	// Settings read from the gist's configuration file, if any.
+	Config *GistConfig `json:"config,omitempty"`
+
+	// Cache validators returned by the GitHub API.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// File returns a file in the gist by name. Returns nil if not found.
+func (g *Gist) File(filename string) *GistFile {
+	if g == nil {
+		return nil
+	}
+	for _, f := range g.Files {
+		if f.Filename == filename {
+			return f
+		}
+	}
+	return nil
+}
+
+// fileByRawURL returns a file in the gist by raw URL. Returns nil if not found.
+func (g *Gist) fileByRawURL(rawurl string) *GistFile {
+	if g == nil {
+		return nil
+	}
+	for _, f := range g.Files {
+		if f.RawURL == rawurl {
+			return f
+		}
+	}
+	return nil
+}
+
+// ValidGistID returns true if id is a well-formed gist ID. IDs may only
+// contain ASCII letters and digits so they are safe to use in paths.
+func ValidGistID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, ch := range id {
+		if !(ch >= '0' && ch <= '9') && !(ch >= 'a' && ch <= 'z') && !(ch >= 'A' && ch <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidFilename returns true if name is safe to use as a gist filename.
+// Filenames cannot be blank, "." or "..", or contain path separators or
+// control characters.
+func ValidFilename(name string) bool {
+	if name == "" || name == "." || name == ".." || len(name) > 255 {
+		return false
+	}
+	for _, ch := range name {
+		if ch == '/' || ch == '\\' || ch < 0x20 || ch == 0x7f {
+			return false
+		}
+	}
+	return true
 }
 
 // GistFile represents an individual file within a gist.
+// The hash references the file content in the blob store.
 type GistFile struct {
-	Size     int    `json:"size"`
-	Filename string `json:"filename"`
-	RawURL   string `json:"rawURL"`
+	Size      int    `json:"size"`
+	Filename  string `json:"filename"`
+	RawURL    string `json:"rawURL"`
+	Hash      string `json:"hash,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Language  string `json:"language,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+
+	// Inline content returned by the API and its encoding. Only complete
+	// if not truncated. The content of binary files is not their raw bytes.
+	content  []byte
+	encoding string
+}
+
+// inlineContent returns the content returned by the API if it can be stored
+// as the file. Returns nil if the full content must be downloaded.
+func (f *GistFile) inlineContent() []byte {
+	if f.content == nil || f.Truncated || !utf8.Valid(f.content) {
+		return nil
+	} else if f.Size != 0 && len(f.content) != f.Size {
+		return nil
+	}
+
+	// Only text is returned as-is. Files without metadata are downloaded.
+	switch {
+	case f.encoding != "":
+		if !strings.EqualFold(f.encoding, "utf-8") {
+			return nil
+		}
+	case f.Type == "" || !isTextType(f.Type):
+		return nil
+	}
+	return f.content
+}
+
+// isTextType returns true if a media type is a text format.
+func isTextType(typ string) bool {
+	typ, _, _ = mime.ParseMediaType(typ)
+	switch {
+	case strings.HasPrefix(typ, "text/"), strings.HasSuffix(typ, "+json"), strings.HasSuffix(typ, "+xml"):
+		return true
+	case strings.HasPrefix(typ, "image/"), strings.HasPrefix(typ, "audio/"), strings.HasPrefix(typ, "video/"), strings.HasPrefix(typ, "font/"):
+		return false
+	}
+
+	// Source code is commonly reported as an application type, such as
+	// "application/x-python", so only known binary formats are excluded.
+	switch typ {
+	case "application/octet-stream", "application/pdf", "application/zip", "application/gzip",
+		"application/x-gzip", "application/x-tar", "application/wasm", "application/vnd.ms-fontobject",
+		"application/x-font-ttf", "application/font-woff", "application/x-shockwave-flash":
+		return false
+	}
+	return strings.HasPrefix(typ, "application/")
+}
+
+// GistConfigFilename is the name of the optional file in a gist that
+// configures how the gist is served.
+const GistConfigFilename = ".gistconfig"
+
+// GistConfig represents the per-gist settings in the configuration file.
+//
+// Example:
+//
+//	{"cache": {"maxAge": 300, "staleWhileRevalidate": 60}}
+type GistConfig struct {
+	Cache *CachePolicy `json:"cache,omitempty"`
+}
+
+// CachePolicy represents the caching rules for served gist files.
+// Ages are in seconds.
+type CachePolicy struct {
+	MaxAge               int  `json:"maxAge"`
+	StaleWhileRevalidate int  `json:"staleWhileRevalidate,omitempty"`
+	Immutable            bool `json:"immutable,omitempty"`
+}
+
+// CacheControl returns the value of the Cache-Control header for the policy.
+// Immutable files are cached for a year if the max age is not set.
+func (p *CachePolicy) CacheControl() string {
+	maxAge := p.MaxAge
+	if p.Immutable && maxAge <= 0 {
+		maxAge = 31536000
+	} else if maxAge <= 0 {
+		return "no-cache"
+	}
+
+	s := "public, max-age=" + strconv.Itoa(maxAge)
+	if p.StaleWhileRevalidate > 0 {
+		s += ", stale-while-revalidate=" + strconv.Itoa(p.StaleWhileRevalidate)
+	}
+	if p.Immutable {
+		s += ", immutable"
+	}
+	return s
+}
+
+// SizeLimitError is returned when a gist file or the gist as a whole
+// exceeds the configured size limit.
+type SizeLimitError struct {
+	GistID   string
+	Filename string // blank if the whole gist is too large
+	Limit    int64
+}
+
+// Error returns the error message.
+func (e *SizeLimitError) Error() string {
+	if e.Filename != "" {
+		return fmt.Sprintf("gist file too large: %s/%s exceeds limit of %d bytes", e.GistID, e.Filename, e.Limit)
+	}
+	return fmt.Sprintf("gist too large: %s exceeds limit of %d bytes", e.GistID, e.Limit)
+}
+
+// GistList represents a single page of a user's gists on GitHub.
+type GistList struct {
+	Gists []*Gist `json:"gists"`
+	Next  int     `json:"next"` // zero if there are no more pages
+
+	// Cache validators returned by the GitHub API.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// SyncStatus represents the result of the last background sync of a gist.
+type SyncStatus struct {
+	SyncedAt time.Time `json:"syncedAt"`
+	Error    string    `json:"error,omitempty"`
 }
 
 // User represents a GitHub authorized user on the system.
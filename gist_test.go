@@ -8,14 +8,92 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/benbjohnson/gist"
 )
 
 func init() {
 	log.SetFlags(0)
 }
 
+// Ensure that gist IDs are validated.
+func TestValidGistID(t *testing.T) {
+	var tests = []struct {
+		id    string
+		valid bool
+	}{
+		{"aa5a315d61ae9438b18d", true},
+		{"1234", true},
+		{"AbC123", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../db", false},
+		{"abc/def", false},
+		{"abc\\def", false},
+		{"abc.git", false},
+		{"-abc", false},
+		{"abc\x00", false},
+		{"abc def", false},
+		{strings.Repeat("a", 65), false},
+	}
+	for i, tt := range tests {
+		if v := gist.ValidGistID(tt.id); v != tt.valid {
+			t.Errorf("%d. %q: exp: %v, got: %v", i, tt.id, tt.valid, v)
+		}
+	}
+}
+
+// Ensure that gist filenames are validated.
+func TestValidFilename(t *testing.T) {
+	var tests = []struct {
+		name  string
+		valid bool
+	}{
+		{"index.html", true},
+		{"odd name #?.txt", true},
+		{".gitignore", true},
+		{"..foo", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../db", false},
+		{"css/style.css", false},
+		{"/etc/passwd", false},
+		{"..\\db", false},
+		{"a\x00b", false},
+		{"a\nb", false},
+		{strings.Repeat("a", 256), false},
+	}
+	for i, tt := range tests {
+		if v := gist.ValidFilename(tt.name); v != tt.valid {
+			t.Errorf("%d. %q: exp: %v, got: %v", i, tt.name, tt.valid, v)
+		}
+	}
+}
+
+// Ensure that a cache policy is converted to a Cache-Control header.
+func TestCachePolicy_CacheControl(t *testing.T) {
+	var tests = []struct {
+		policy gist.CachePolicy
+		value  string
+	}{
+		{gist.CachePolicy{}, "no-cache"},
+		{gist.CachePolicy{MaxAge: 60}, "public, max-age=60"},
+		{gist.CachePolicy{MaxAge: 60, StaleWhileRevalidate: 3600}, "public, max-age=60, stale-while-revalidate=3600"},
+		{gist.CachePolicy{Immutable: true}, gist.ImmutableCacheControl},
+		{gist.CachePolicy{MaxAge: 600, Immutable: true}, "public, max-age=600, immutable"},
+	}
+	for i, tt := range tests {
+		if v := tt.policy.CacheControl(); v != tt.value {
+			t.Errorf("%d. exp: %s, got: %s", i, tt.value, v)
+		}
+	}
+}
+
 // assert fails the test if the condition is false.
 func assert(tb testing.TB, condition bool, msg string, v ...interface{}) {
 	if !condition {
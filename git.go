@@ -0,0 +1,179 @@
+package gist
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gitHeadRef is the local ref that tracks the default branch of a gist.
+const gitHeadRef = "refs/gist/head"
+
+// RepoPath returns the path to the bare git repository of a gist.
+// Returns a blank path if the gist ID is invalid.
+func (db *DB) RepoPath(gistID string) string {
+	if !ValidGistID(gistID) {
+		return ""
+	}
+	path, _ := db.Root().Path("repos", gistID+".git")
+	return path
+}
+
+// fetchGit fetches the git repository of a gist into a bare repository under
+// the gist path and writes each file from the gist's revision into the
+// staging directory. The repository keeps the full history of the gist so
+// only new objects are transferred on later fetches.
+func (db *DB) fetchGit(gist *Gist, dir string) error {
+	if gist.GitPullURL == "" {
+		return fmt.Errorf("git pull url required: %s", gist.ID)
+	} else if strings.HasPrefix(gist.GitPullURL, "-") {
+		return fmt.Errorf("invalid git pull url: %q", gist.GitPullURL)
+	}
+
+	// Clone or update the bare repository.
+	repo := db.RepoPath(gist.ID)
+	if repo == "" {
+		return fmt.Errorf("invalid gist id: %q", gist.ID)
+	} else if err := gitFetch(repo, gist.GitPullURL); err != nil {
+		return fmt.Errorf("git fetch: %s", err)
+	}
+
+	// Use the latest commit if the revision is unknown.
+	rev := gist.Revision
+	if rev == "" {
+		rev = gitHeadRef
+	} else if !IsRevision(rev) {
+		return fmt.Errorf("invalid revision: %s", rev)
+	}
+
+	// Read the file list from the revision.
+	tree, err := gitTree(repo, rev)
+	if err != nil {
+		return fmt.Errorf("git tree: %s", err)
+	}
+
+	// Copy each file out of the repository.
+	for i, file := range gist.Files {
+		obj, ok := tree[file.Filename]
+		if !ok {
+			return fmt.Errorf("file not found in revision: %s", file.Filename)
+		}
+
+		hash, n, err := gitCatBlob(repo, obj, stagedFilePath(dir, i), db.MaxFileSize)
+		if err == errFileTooLarge {
+			return &SizeLimitError{GistID: gist.ID, Filename: file.Filename, Limit: db.MaxFileSize}
+		} else if err != nil {
+			return fmt.Errorf("git cat-file: %s: %s", file.Filename, err)
+		}
+		file.Hash, file.Size = hash, int(n)
+	}
+
+	return nil
+}
+
+// gitFetch fetches the default branch of a remote repository into a local
+// bare repository. The repository is created if it does not exist.
+func gitFetch(repo, url string) error {
+	if _, err := os.Stat(repo); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(repo), 0700); err != nil {
+			return err
+		}
+		if _, err := git("", "init", "--quiet", "--bare", repo); err != nil {
+			_ = os.RemoveAll(repo)
+			return err
+		}
+	}
+
+	_, err := git(repo, "fetch", "--quiet", "--no-tags", url, "+HEAD:"+gitHeadRef)
+	return err
+}
+
+// gitTree returns the object IDs of the files at a revision, keyed by filename.
+func gitTree(repo, rev string) (map[string]string, error) {
+	out, err := git(repo, "ls-tree", "-z", rev)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each entry is formatted as "<mode> <type> <object>\t<filename>\x00".
+	m := make(map[string]string)
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		if len(entry) == 0 {
+			continue
+		}
+		i := bytes.IndexByte(entry, '\t')
+		if i == -1 {
+			return nil, fmt.Errorf("invalid tree entry: %q", entry)
+		}
+		fields := bytes.Fields(entry[:i])
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid tree entry: %q", entry)
+		} else if string(fields[1]) != "blob" {
+			continue
+		}
+		m[string(entry[i+1:])] = string(fields[2])
+	}
+	return m, nil
+}
+
+// gitCatBlob writes the content of a blob object to path. If limit is greater
+// than zero then larger blobs return errFileTooLarge. Returns the SHA-256 hash
+// of the content and the number of bytes written.
+func gitCatBlob(repo, obj, path string, limit int64) (string, int64, error) {
+	cmd := gitCommand(repo, "cat-file", "blob", obj)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", 0, err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", 0, err
+	}
+
+	// Read up to one byte past the limit so larger blobs can be detected.
+	var r io.Reader = stdout
+	if limit > 0 {
+		r = io.LimitReader(stdout, limit+1)
+	}
+	hash, n, err := writeFile(path, r)
+	if err != nil || (limit > 0 && n > limit) {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		if err == nil {
+			err = errFileTooLarge
+		}
+		return "", 0, err
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return "", 0, fmt.Errorf("%s: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return hash, n, nil
+}
+
+// git runs a git command against a repository and returns its output.
+func git(repo string, args ...string) ([]byte, error) {
+	out, err := gitCommand(repo, args...).Output()
+	if e, ok := err.(*exec.ExitError); ok {
+		return nil, fmt.Errorf("git %s: %s", args[0], bytes.TrimSpace(e.Stderr))
+	} else if err != nil {
+		return nil, fmt.Errorf("git %s: %s", args[0], err)
+	}
+	return out, nil
+}
+
+// gitCommand returns a git command for a repository. Prompts are disabled
+// so a fetch that requires credentials fails instead of blocking.
+func gitCommand(repo string, args ...string) *exec.Cmd {
+	if repo != "" {
+		args = append([]string{"--git-dir", repo}, args...)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	return cmd
+}
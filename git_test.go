@@ -0,0 +1,124 @@
+package gist_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/benbjohnson/gist"
+)
+
+// Ensure that a gist can be fetched from its git repository.
+func TestDB_LoadGist_Git(t *testing.T) {
+	// Create a local repository with a file that has an unusual name.
+	work, bare := tempfile(), tempfile()
+	defer os.RemoveAll(work)
+	defer os.RemoveAll(bare)
+	mustGit(t, "", "init", "--quiet", work)
+	mustWriteFile(t, filepath.Join(work, "hello.txt"), "hello")
+	mustWriteFile(t, filepath.Join(work, "odd name #?.txt"), "odd")
+	mustGit(t, work, "add", ".")
+	mustGit(t, work, "commit", "--quiet", "-m", "first")
+	rev0 := mustGit(t, work, "rev-parse", "HEAD")
+	mustGit(t, "", "clone", "--quiet", "--bare", work, bare)
+
+	db := NewTestDB()
+	defer db.Close()
+	db.FetchMode = gist.FetchModeGit
+	db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"})
+
+	// Return the current revision from the mock API.
+	var rev string
+	client := &MockGitHubClient{}
+	client.GistFunc = func(id string) (*gist.Gist, error) {
+		return &gist.Gist{ID: "xxx", UserID: 1000, Revision: rev, GitPullURL: bare, Files: []*gist.GistFile{
+			{Filename: "hello.txt"},
+			{Filename: "odd name #?.txt"},
+		}}, nil
+	}
+	db.NewGitHubClient = func(_ string) gist.GitHubClient { return client }
+
+	// Load the first revision.
+	rev = rev0
+	ok(t, db.LoadGist(1000, "xxx"))
+	equals(t, "hello", MustReadGistFile(db, "xxx", "", "hello.txt"))
+	equals(t, "odd", MustReadGistFile(db, "xxx", "", "odd name #?.txt"))
+
+	// Push a new revision and reload the gist.
+	mustWriteFile(t, filepath.Join(work, "hello.txt"), "hello, world")
+	mustGit(t, work, "commit", "--quiet", "-am", "second")
+	rev1 := mustGit(t, work, "rev-parse", "HEAD")
+	mustGit(t, work, "push", "--quiet", bare, "HEAD")
+
+	rev = rev1
+	ok(t, db.LoadGist(1000, "xxx"))
+	equals(t, "hello, world", MustReadGistFile(db, "xxx", "", "hello.txt"))
+	equals(t, "hello", MustReadGistFile(db, "xxx", rev0, "hello.txt"))
+
+	// Verify that the history is kept in the local repository.
+	_, err := os.Stat(db.RepoPath("xxx"))
+	ok(t, err)
+	out, err := exec.Command("git", "--git-dir", db.RepoPath("xxx"), "rev-list", rev1).Output()
+	ok(t, err)
+	equals(t, rev1+"\n"+rev0+"\n", string(out))
+}
+
+// Ensure that fetching a file missing from the git revision returns an error.
+func TestDB_LoadGist_Git_ErrFileNotFound(t *testing.T) {
+	work, bare := tempfile(), tempfile()
+	defer os.RemoveAll(work)
+	defer os.RemoveAll(bare)
+	mustGit(t, "", "init", "--quiet", work)
+	mustWriteFile(t, filepath.Join(work, "hello.txt"), "hello")
+	mustGit(t, work, "add", ".")
+	mustGit(t, work, "commit", "--quiet", "-m", "first")
+	mustGit(t, "", "clone", "--quiet", "--bare", work, bare)
+
+	db := NewTestDB()
+	defer db.Close()
+	db.FetchMode = gist.FetchModeGit
+	db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"})
+
+	client := &MockGitHubClient{}
+	client.GistFunc = func(id string) (*gist.Gist, error) {
+		return &gist.Gist{ID: "xxx", UserID: 1000, GitPullURL: bare, Files: []*gist.GistFile{{Filename: "missing.txt"}}}, nil
+	}
+	db.NewGitHubClient = func(_ string) gist.GitHubClient { return client }
+
+	err := db.LoadGist(1000, "xxx")
+	assert(t, err != nil && strings.Contains(err.Error(), "file not found in revision: missing.txt"), "unexpected error: %v", err)
+}
+
+// MustReadGistFile returns the content of a gist file. Panic on error.
+func MustReadGistFile(db *TestDB, gistID, revision, filename string) string {
+	path, err := db.GistFilePath(gistID, revision, filename)
+	if err != nil {
+		panic(err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+// mustGit runs a git command in dir and returns its trimmed output.
+func mustGit(tb testing.TB, dir string, args ...string) string {
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		tb.Fatalf("git %s: %s: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func mustWriteFile(tb testing.TB, path, s string) {
+	if err := ioutil.WriteFile(path, []byte(s), 0600); err != nil {
+		tb.Fatal(err)
+	}
+}
@@ -1,40 +1,249 @@
 package gist
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"code.google.com/p/goauth2/oauth"
 	"github.com/google/go-github/github"
 )
 
+// ErrNotModified is returned when a gist has not changed since it was cached.
+var ErrNotModified = errors.New("not modified")
+
 // GitHubClient is an interface for abstracting the GitHub API.
 type GitHubClient interface {
 	SetBaseURL(u string)
 	User(username string) (*User, error)
-	Gists(username string) ([]*Gist, error)
 	Gist(id string) (*Gist, error)
+
+	// GistsPageIfModified returns a single page of gists for a user, starting
+	// from 1, only if it has changed since the given cache validators were
+	// returned. Otherwise returns ErrNotModified. The next page is zero when
+	// there are no more pages.
+	GistsPageIfModified(username string, page int, etag, lastModified string) (*GistList, error)
+
+	// GistIfModified returns a gist only if it has changed since the given
+	// cache validators were returned. Otherwise returns ErrNotModified.
+	GistIfModified(id, etag, lastModified string) (*Gist, error)
+
+	// Rate returns the rate limit status from the last API response.
+	Rate() Rate
+}
+
+// Rate represents the GitHub API rate limit status.
+type Rate struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// RateLimitError is returned when GitHub is throttling API requests.
+type RateLimitError struct {
+	Reset   time.Time
+	Message string
+}
+
+// Error returns the error message.
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("github rate limit: %s (reset at %s)", e.Message, e.Reset.Format(time.RFC3339))
 }
 
-// NewGitHubClient returns an instance of GitHubClient using a given access token.
+const (
+	// maxRetries is the number of times a failed request is retried.
+	maxRetries = 3
+
+	// retryBackoff is the initial delay between retries. It doubles with each retry.
+	retryBackoff = 100 * time.Millisecond
+
+	// maxRetryDelay is the longest delay the client waits before retrying.
+	// Longer delays requested by GitHub are returned as a RateLimitError.
+	maxRetryDelay = 10 * time.Second
+)
+
+// NewGitHubClient returns an instance of GitHubClient for github.com using a given access token.
 func NewGitHubClient(token string) GitHubClient {
+	return (*GitHubConfig)(nil).NewClient(token)
+}
+
+// Default endpoints for github.com.
+const (
+	DefaultGitHubAPIURL    = "https://api.github.com/"
+	DefaultGitHubUploadURL = "https://uploads.github.com/"
+	DefaultGitHubAuthURL   = "https://github.com/login/oauth/authorize"
+	DefaultGitHubTokenURL  = "https://github.com/login/oauth/access_token"
+)
+
+// GitHubConfig represents the endpoints used to access GitHub. Blank fields
+// use the github.com defaults so it only needs to be set for GitHub Enterprise.
+type GitHubConfig struct {
+	// Base URLs for the REST API and uploads.
+	APIURL    string
+	UploadURL string
+
+	// RawHost replaces the host of raw file URLs returned by the API.
+	RawHost string
+
+	// OAuth authorization and token exchange URLs.
+	AuthURL  string
+	TokenURL string
+}
+
+// NewClient returns an instance of GitHubClient using a given access token.
+func (c *GitHubConfig) NewClient(token string) GitHubClient {
 	t := &oauth.Transport{Token: &oauth.Token{AccessToken: token}}
-	return &gitHubClient{github.NewClient(t.Client())}
+	client := github.NewClient(t.Client())
+	client.BaseURL, _ = url.Parse(c.apiURL())
+	client.UploadURL, _ = url.Parse(c.uploadURL())
+	return &gitHubClient{Client: client}
+}
+
+func (c *GitHubConfig) apiURL() string {
+	if c == nil || c.APIURL == "" {
+		return DefaultGitHubAPIURL
+	}
+	return withTrailingSlash(c.APIURL)
+}
+
+func (c *GitHubConfig) uploadURL() string {
+	if c == nil || c.UploadURL == "" {
+		return DefaultGitHubUploadURL
+	}
+	return withTrailingSlash(c.UploadURL)
+}
+
+func (c *GitHubConfig) authURL() string {
+	if c == nil || c.AuthURL == "" {
+		return DefaultGitHubAuthURL
+	}
+	return c.AuthURL
+}
+
+func (c *GitHubConfig) tokenURL() string {
+	if c == nil || c.TokenURL == "" {
+		return DefaultGitHubTokenURL
+	}
+	return c.TokenURL
+}
+
+// rawURL returns the URL to download a raw file from.
+func (c *GitHubConfig) rawURL(rawurl string) (string, error) {
+	if c == nil || c.RawHost == "" {
+		return rawurl, nil
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	u.Host = c.RawHost
+	return u.String(), nil
+}
+
+// withTrailingSlash returns s with a trailing slash. The API client resolves
+// paths relative to the base URL so a missing slash would drop a path segment.
+func withTrailingSlash(s string) string {
+	if !strings.HasSuffix(s, "/") {
+		return s + "/"
+	}
+	return s
 }
 
 // gitHubClient wraps the third-party client to implement the GitHubClient interface.
 type gitHubClient struct {
 	*github.Client
+
+	mu   sync.Mutex
+	rate Rate
 }
 
 // SetBaseURL sets the base URL for testing.
 func (c *gitHubClient) SetBaseURL(rawurl string) { c.BaseURL, _ = url.Parse(rawurl) }
 
+// Rate returns the rate limit status from the last API response.
+func (c *gitHubClient) Rate() Rate {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rate
+}
+
+// retry executes fn and retries with an exponential backoff on server errors
+// and secondary rate limits. If GitHub's rate limit is exhausted then a
+// *RateLimitError is returned without retrying.
+func (c *gitHubClient) retry(fn func() (*github.Response, error)) (*github.Response, error) {
+	for i := 0; ; i++ {
+		resp, err := fn()
+
+		// Record the rate limit status.
+		if resp != nil && resp.Response != nil && resp.Limit > 0 {
+			c.mu.Lock()
+			c.rate = Rate{Limit: resp.Limit, Remaining: resp.Remaining, Reset: resp.Reset.Time}
+			c.mu.Unlock()
+		}
+		if err == nil {
+			return resp, nil
+		}
+
+		// Determine if the request can be retried and how long to wait.
+		delay := retryBackoff << uint(i)
+		switch e := err.(type) {
+		case *github.RateLimitError:
+			return resp, &RateLimitError{Reset: e.Rate.Reset.Time, Message: e.Message}
+		default:
+			// Secondary rate limits are reported with a Retry-After header.
+			if d, ok := retryAfter(resp); ok {
+				delay = d
+				if i >= maxRetries || delay > maxRetryDelay {
+					return resp, &RateLimitError{Reset: time.Now().Add(delay), Message: errorMessage(err)}
+				}
+			} else if resp == nil || resp.Response == nil || resp.StatusCode < 500 || i >= maxRetries {
+				return resp, err
+			}
+		}
+
+		time.Sleep(delay)
+	}
+}
+
+// retryAfter returns the delay requested by a secondary rate limit response.
+func retryAfter(resp *github.Response) (time.Duration, bool) {
+	if resp == nil || resp.Response == nil {
+		return 0, false
+	} else if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	n, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// errorMessage returns the message from a GitHub API error.
+func errorMessage(err error) string {
+	if e, ok := err.(*github.ErrorResponse); ok {
+		return e.Message
+	}
+	return err.Error()
+}
+
 // User returns a user by username.
 func (c *gitHubClient) User(username string) (*User, error) {
 	// Retrieve user from GitHub.
-	user, _, err := c.Users.Get(username)
-	if err != nil {
+	var user *github.User
+	_, err := c.retry(func() (resp *github.Response, err error) {
+		user, resp, err = c.Users.Get(username)
+		return
+	})
+	if _, ok := err.(*RateLimitError); ok {
+		return nil, err
+	} else if err != nil {
 		return nil, fmt.Errorf("get user: %s", err)
 	}
 
@@ -49,39 +258,145 @@ func (c *gitHubClient) User(username string) (*User, error) {
 	return u, nil
 }
 
-// Gists returns a list of gists for a user.
-func (c *gitHubClient) Gists(username string) ([]*Gist, error) {
+// GistsPageIfModified returns a single page of gists for a user using a
+// conditional request. Returns ErrNotModified if the page is unchanged.
+func (c *gitHubClient) GistsPageIfModified(username string, page int, etag, lastModified string) (*GistList, error) {
+	u := "gists"
+	if username != "" {
+		u = "users/" + url.QueryEscape(username) + "/gists"
+	}
+	if page > 0 {
+		u += "?page=" + strconv.Itoa(page)
+	}
+
 	// Retrieve gists from GitHub.
-	a, _, err := c.Client.Gists.List(username, nil)
-	if err != nil {
+	var a []github.Gist
+	resp, err := c.retry(func() (*github.Response, error) {
+		req, err := c.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("new request: %s", err)
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+		return c.Do(req, &a)
+	})
+	if resp != nil && resp.Response != nil && resp.StatusCode == http.StatusNotModified {
+		return nil, ErrNotModified
+	} else if _, ok := err.(*RateLimitError); ok {
+		return nil, err
+	} else if err != nil {
 		return nil, fmt.Errorf("list gists: %s", err)
 	}
 
 	// Convert to our application type.
-	var gists []*Gist
+	l := &GistList{
+		Next:         resp.NextPage,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
 	for _, item := range a {
 		gist := &Gist{}
 		gist.deserializeGist(&item, false)
-		gists = append(gists, gist)
+		l.Gists = append(l.Gists, gist)
 	}
-
-	return gists, nil
+	return l, nil
 }
 
 // Gist returns a single gist by ID (with content).
 func (c *gitHubClient) Gist(id string) (*Gist, error) {
-	// Retrieve gist from GitHub.
-	item, _, err := c.Client.Gists.Get(id)
-	if err != nil {
+	return c.GistIfModified(id, "", "")
+}
+
+// GistIfModified returns a single gist by ID (with content) using a
+// conditional request. Returns ErrNotModified if the gist is unchanged.
+func (c *gitHubClient) GistIfModified(id, etag, lastModified string) (*Gist, error) {
+	// Retrieve gist from GitHub. The history and some file fields are not
+	// exposed by the third-party client so the response is decoded into our
+	// own type.
+	var item gistResponse
+	resp, err := c.retry(func() (*github.Response, error) {
+		req, err := c.NewRequest("GET", "gists/"+id, nil)
+		if err != nil {
+			return nil, fmt.Errorf("new request: %s", err)
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+		return c.Do(req, &item)
+	})
+	if resp != nil && resp.Response != nil && resp.StatusCode == http.StatusNotModified {
+		return nil, ErrNotModified
+	} else if _, ok := err.(*RateLimitError); ok {
+		return nil, err
+	} else if err != nil {
 		return nil, fmt.Errorf("get gist: %s", err)
 	}
 
 	// Convert to our application type.
 	gist := &Gist{}
-	gist.deserializeGist(item, true)
+	gist.deserializeGist(&item.Gist, true)
+	for _, file := range item.Files {
+		f := &GistFile{}
+		f.deserializeGistFile(&file.GistFile, true)
+		if file.Type != nil {
+			f.Type = *file.Type
+		}
+		if file.Language != nil {
+			f.Language = *file.Language
+		}
+		if file.Truncated != nil {
+			f.Truncated = *file.Truncated
+		}
+		if file.Encoding != nil {
+			f.encoding = *file.Encoding
+		}
+		gist.Files = append(gist.Files, f)
+	}
+
+	// The first history entry is the current revision.
+	if len(item.History) > 0 {
+		if v := item.History[0].Version; v != nil {
+			gist.Revision = *v
+		}
+		if v := item.History[0].CommittedAt; v != nil {
+			gist.UpdatedAt = *v
+		}
+	}
+
+	// Save the cache validators for the next request.
+	gist.ETag = resp.Header.Get("ETag")
+	gist.LastModified = resp.Header.Get("Last-Modified")
+
 	return gist, nil
 }
 
+// gistResponse represents a single gist returned by the GitHub API. It
+// includes the history and file fields not exposed by the third-party client.
+type gistResponse struct {
+	github.Gist
+	Files   map[github.GistFilename]gistFileResponse `json:"files,omitempty"`
+	History []struct {
+		Version     *string    `json:"version,omitempty"`
+		CommittedAt *time.Time `json:"committed_at,omitempty"`
+	} `json:"history,omitempty"`
+}
+
+// gistFileResponse represents a single gist file returned by the GitHub API.
+type gistFileResponse struct {
+	github.GistFile
+	Type      *string `json:"type,omitempty"`
+	Language  *string `json:"language,omitempty"`
+	Truncated *bool   `json:"truncated,omitempty"`
+	Encoding  *string `json:"encoding,omitempty"`
+}
+
 func (g *Gist) deserializeGist(item *github.Gist, useContent bool) {
 	if item.ID != nil {
 		g.ID = *item.ID
@@ -98,21 +413,34 @@ func (g *Gist) deserializeGist(item *github.Gist, useContent bool) {
 	if item.HTMLURL != nil {
 		g.URL = *item.HTMLURL
 	}
+	if item.GitPullURL != nil {
+		g.GitPullURL = *item.GitPullURL
+	}
 	if item.CreatedAt != nil {
 		g.CreatedAt = *item.CreatedAt
 	}
+	if item.UpdatedAt != nil {
+		g.UpdatedAt = *item.UpdatedAt
+	}
 
 	for _, file := range item.Files {
 		f := &GistFile{}
-		if file.Size != nil {
-			f.Size = *file.Size
-		}
-		if file.Filename != nil {
-			f.Filename = *file.Filename
-		}
-		if file.RawURL != nil {
-			f.RawURL = *file.RawURL
-		}
+		f.deserializeGistFile(&file, useContent)
 		g.Files = append(g.Files, f)
 	}
 }
+
+func (f *GistFile) deserializeGistFile(file *github.GistFile, useContent bool) {
+	if file.Size != nil {
+		f.Size = *file.Size
+	}
+	if file.Filename != nil {
+		f.Filename = *file.Filename
+	}
+	if file.RawURL != nil {
+		f.RawURL = *file.RawURL
+	}
+	if useContent && file.Content != nil {
+		f.content = []byte(*file.Content)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/benbjohnson/gist"
 )
@@ -28,6 +29,21 @@ func TestGitHub_User(t *testing.T) {
 	equals(t, "", u.AccessToken)
 }
 
+// Ensure that the GitHub client can be configured to use GitHub Enterprise.
+func TestGitHubConfig_NewClient(t *testing.T) {
+	// Create mock GitHub Enterprise API server.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equals(t, "/api/v3/users/john", r.URL.Path)
+		fmt.Fprint(w, `{"login": "john","id":1000}`)
+	}))
+	defer s.Close()
+
+	config := &gist.GitHubConfig{APIURL: s.URL + "/api/v3"}
+	u, err := config.NewClient("xyz").User("john")
+	ok(t, err)
+	equals(t, 1000, u.ID)
+}
+
 // Ensure that the GitHub client handles a server error appropriately.
 func TestGitHub_User_ErrInternalServerError(t *testing.T) {
 	// Create mock GitHub API server that returns an error.
@@ -56,9 +72,10 @@ func TestGitHub_Gists(t *testing.T) {
 	// Create client and request the user "john".
 	c := gist.NewGitHubClient("xyz")
 	c.SetBaseURL(s.URL)
-	a, err := c.Gists("foo")
+	l, err := c.GistsPageIfModified("foo", 0, "", "")
 	ok(t, err)
 
+	a := l.Gists
 	equals(t, 1, len(a))
 	equals(t, "25f126746be9275592eb", a[0].ID)
 	equals(t, 1000, a[0].UserID)
@@ -72,6 +89,41 @@ func TestGitHub_Gists(t *testing.T) {
 	equals(t, "https://gist.githubusercontent.com/foo/25f126746be9275592eb/raw/ae491a919ac25988dab39677d5784945242f4d04/gistfile1.diff", a[0].Files[0].RawURL)
 }
 
+// Ensure that the GitHub client follows pagination when listing gists.
+func TestGitHub_Gists_Pagination(t *testing.T) {
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equals(t, "/users/foo/gists", r.URL.Path)
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Header().Set("Link", `<`+s.URL+`/users/foo/gists?page=2>; rel="next", <`+s.URL+`/users/foo/gists?page=2>; rel="last"`)
+			fmt.Fprint(w, `[{"id": "aaa"}]`)
+		case "2":
+			fmt.Fprint(w, `[{"id": "bbb"}]`)
+		default:
+			t.Fatalf("unexpected page: %s", r.URL.RawQuery)
+		}
+	}))
+	defer s.Close()
+
+	c := gist.NewGitHubClient("xyz")
+	c.SetBaseURL(s.URL)
+
+	// Retrieve the first page.
+	l, err := c.GistsPageIfModified("foo", 1, "", "")
+	ok(t, err)
+	equals(t, 1, len(l.Gists))
+	equals(t, "aaa", l.Gists[0].ID)
+	equals(t, 2, l.Next)
+
+	// Retrieve the last page.
+	l, err = c.GistsPageIfModified("foo", l.Next, "", "")
+	ok(t, err)
+	equals(t, 1, len(l.Gists))
+	equals(t, "bbb", l.Gists[0].ID)
+	equals(t, 0, l.Next)
+}
+
 // Ensure that the GitHub client handles a server error appropriately.
 func TestGitHub_Gists_ErrInternalServerError(t *testing.T) {
 	// Create mock GitHub API server that returns an error.
@@ -84,7 +136,7 @@ func TestGitHub_Gists_ErrInternalServerError(t *testing.T) {
 	// Create client and request the user "john".
 	c := gist.NewGitHubClient("xyz")
 	c.SetBaseURL(s.URL)
-	_, err := c.Gists("john")
+	_, err := c.GistsPageIfModified("john", 0, "", "")
 	assert(t, err != nil, "expected error")
 }
 
@@ -93,16 +145,38 @@ func TestGitHub_Gist(t *testing.T) {
 	// Create mock GitHub API server.
 	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		equals(t, "/gists/25f126746be9275592eb", r.URL.Path)
-		fmt.Fprint(w, `{"id": "25f126746be9275592eb","html_url": "https://gist.github.com/25f126746be9275592eb","files": {"gistfile1.diff": {"filename": "gistfile1.diff","raw_url": "https://gist.githubusercontent.com/foo/25f126746be9275592eb/raw/ae491a919ac25988dab39677d5784945242f4d04/gistfile1.diff","size": 3073}},"public": true,"description": "My gist","owner": {"login": "foo","id":1000}}`)
+		fmt.Fprint(w, `{"id": "25f126746be9275592eb","html_url": "https://gist.github.com/25f126746be9275592eb","files": {"gistfile1.diff": {"filename": "gistfile1.diff","raw_url": "https://gist.githubusercontent.com/foo/25f126746be9275592eb/raw/ae491a919ac25988dab39677d5784945242f4d04/gistfile1.diff","size": 3073}},"public": true,"description": "My gist","owner": {"login": "foo","id":1000},"history": [{"version": "57a7f021a713b1c5a6a199b54cc514735d2d462f","committed_at": "2010-04-14T02:15:15Z"},{"version": "3bc4ab2a3a8f4c8c2d1e5b35c6d1a2f4f7a6e0e1","committed_at": "2010-04-13T02:15:15Z"}]}`)
 	}))
 	defer s.Close()
 
 	// Create client and request the user "john".
 	c := gist.NewGitHubClient("xyz")
 	c.SetBaseURL(s.URL)
-	gist, err := c.Gist("25f126746be9275592eb")
+	g, err := c.Gist("25f126746be9275592eb")
 	ok(t, err)
-	equals(t, "25f126746be9275592eb", gist.ID)
+	equals(t, "25f126746be9275592eb", g.ID)
+	equals(t, "57a7f021a713b1c5a6a199b54cc514735d2d462f", g.Revision)
+	equals(t, parsetime("2010-04-14T02:15:15Z"), g.UpdatedAt)
+}
+
+// Ensure that the GitHub client parses file metadata and truncation.
+func TestGitHub_Gist_Truncated(t *testing.T) {
+	// Create mock GitHub API server.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": "xxx","files": {"main.go": {"filename": "main.go","type": "text/plain","language": "Go","raw_url": "https://gist.githubusercontent.com/foo/xxx/raw/1/main.go","size": 1048576,"truncated": true,"content": "package main"}},"owner": {"login": "foo","id":1000}}`)
+	}))
+	defer s.Close()
+
+	c := gist.NewGitHubClient("xyz")
+	c.SetBaseURL(s.URL)
+	g, err := c.Gist("xxx")
+	ok(t, err)
+	equals(t, 1, len(g.Files))
+	equals(t, "main.go", g.Files[0].Filename)
+	equals(t, "text/plain", g.Files[0].Type)
+	equals(t, "Go", g.Files[0].Language)
+	equals(t, 1048576, g.Files[0].Size)
+	equals(t, true, g.Files[0].Truncated)
 }
 
 // Ensure that the GitHub client handles a server error appropriately.
@@ -121,11 +195,150 @@ func TestGitHub_Gist_ErrInternalServerError(t *testing.T) {
 	assert(t, err != nil, "expected error")
 }
 
+// Ensure that the GitHub client sends cache validators and handles an unchanged gist.
+func TestGitHub_GistIfModified(t *testing.T) {
+	// Create mock GitHub API server that returns 304 if the ETag matches.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equals(t, "/gists/25f126746be9275592eb", r.URL.Path)
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			equals(t, "Mon, 02 Jan 2006 15:04:05 GMT", r.Header.Get("If-Modified-Since"))
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		fmt.Fprint(w, `{"id": "25f126746be9275592eb"}`)
+	}))
+	defer s.Close()
+
+	// The first request should return the gist with its validators.
+	c := gist.NewGitHubClient("xyz")
+	c.SetBaseURL(s.URL)
+	g, err := c.Gist("25f126746be9275592eb")
+	ok(t, err)
+	equals(t, `"abc"`, g.ETag)
+	equals(t, "Mon, 02 Jan 2006 15:04:05 GMT", g.LastModified)
+
+	// A conditional request should return not modified.
+	_, err = c.GistIfModified("25f126746be9275592eb", g.ETag, g.LastModified)
+	equals(t, gist.ErrNotModified, err)
+}
+
+// Ensure that the GitHub client sends cache validators when listing gists.
+func TestGitHub_GistsPageIfModified(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		equals(t, "/users/john/gists", r.URL.Path)
+		equals(t, "2", r.URL.Query().Get("page"))
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"abc"`)
+		w.Header().Set("Link", `<`+"http://"+r.Host+`/users/john/gists?page=3>; rel="next"`)
+		fmt.Fprint(w, `[{"id": "25f126746be9275592eb", "description": "my gist"}]`)
+	}))
+	defer s.Close()
+
+	c := gist.NewGitHubClient("xyz")
+	c.SetBaseURL(s.URL)
+	l, err := c.GistsPageIfModified("john", 2, "", "")
+	ok(t, err)
+	equals(t, 1, len(l.Gists))
+	equals(t, "my gist", l.Gists[0].Description)
+	equals(t, 3, l.Next)
+	equals(t, `"abc"`, l.ETag)
+
+	_, err = c.GistsPageIfModified("john", 2, l.ETag, "")
+	equals(t, gist.ErrNotModified, err)
+}
+
+// Ensure that the GitHub client exposes the rate limit status.
+func TestGitHub_Rate(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", "1136214245")
+		fmt.Fprint(w, `{"login": "john","id":1000}`)
+	}))
+	defer s.Close()
+
+	c := gist.NewGitHubClient("xyz")
+	c.SetBaseURL(s.URL)
+	_, err := c.User("john")
+	ok(t, err)
+	equals(t, gist.Rate{Limit: 5000, Remaining: 4999, Reset: time.Unix(1136214245, 0)}, c.Rate())
+}
+
+// Ensure that the GitHub client retries requests that fail with a server error.
+func TestGitHub_Retry(t *testing.T) {
+	var n int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n++; n < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, `{"login": "john","id":1000}`)
+	}))
+	defer s.Close()
+
+	c := gist.NewGitHubClient("xyz")
+	c.SetBaseURL(s.URL)
+	u, err := c.User("john")
+	ok(t, err)
+	equals(t, 1000, u.ID)
+	equals(t, 3, n)
+}
+
+// Ensure that the GitHub client returns a typed error when the rate limit is exceeded.
+func TestGitHub_ErrRateLimit(t *testing.T) {
+	var n int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "1136214245")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message": "API rate limit exceeded"}`)
+	}))
+	defer s.Close()
+
+	c := gist.NewGitHubClient("xyz")
+	c.SetBaseURL(s.URL)
+	_, err := c.Gist("25f126746be9275592eb")
+	e, ok := err.(*gist.RateLimitError)
+	assert(t, ok, "unexpected error: %#v", err)
+	equals(t, time.Unix(1136214245, 0), e.Reset)
+	equals(t, "API rate limit exceeded", e.Message)
+	equals(t, 1, n)
+}
+
+// Ensure that a long secondary rate limit is returned as a typed error without retrying.
+func TestGitHub_ErrSecondaryRateLimit(t *testing.T) {
+	var n int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message": "You have exceeded a secondary rate limit"}`)
+	}))
+	defer s.Close()
+
+	c := gist.NewGitHubClient("xyz")
+	c.SetBaseURL(s.URL)
+	_, err := c.Gist("25f126746be9275592eb")
+	e, ok := err.(*gist.RateLimitError)
+	assert(t, ok, "unexpected error: %#v", err)
+	assert(t, e.Reset.After(time.Now().Add(50*time.Second)), "unexpected reset: %s", e.Reset)
+	equals(t, 1, n)
+}
+
 // MockGitHubClient is a mockable GitHub client.
 type MockGitHubClient struct {
-	UserFunc  func(username string) (*gist.User, error)
-	GistsFunc func(username string) ([]*gist.Gist, error)
-	GistFunc  func(id string) (*gist.Gist, error)
+	UserFunc                func(username string) (*gist.User, error)
+	GistFunc                func(id string) (*gist.Gist, error)
+	GistIfModifiedFunc      func(id, etag, lastModified string) (*gist.Gist, error)
+	RateFunc                func() gist.Rate
+	GistsPageIfModifiedFunc func(username string, page int, etag, lastModified string) (*gist.GistList, error)
 }
 
 func NewMockGitHubClient(_ string) gist.GitHubClient {
@@ -138,10 +351,18 @@ func (m *MockGitHubClient) User(username string) (*gist.User, error) {
 	return m.UserFunc(username)
 }
 
-func (m *MockGitHubClient) Gists(username string) ([]*gist.Gist, error) {
-	return m.GistsFunc(username)
-}
-
 func (m *MockGitHubClient) Gist(id string) (*gist.Gist, error) {
 	return m.GistFunc(id)
 }
+
+func (m *MockGitHubClient) GistIfModified(id, etag, lastModified string) (*gist.Gist, error) {
+	return m.GistIfModifiedFunc(id, etag, lastModified)
+}
+
+func (m *MockGitHubClient) GistsPageIfModified(username string, page int, etag, lastModified string) (*gist.GistList, error) {
+	return m.GistsPageIfModifiedFunc(username, page, etag, lastModified)
+}
+
+func (m *MockGitHubClient) Rate() gist.Rate {
+	return m.RateFunc()
+}
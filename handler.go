@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"crypto/rand"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	"hash/crc32"
 	"html"
 	"io"
+	"io/ioutil"
 	"log"
+	"math"
 	"mime"
 	"net/http"
 	"net/url"
@@ -16,6 +20,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"code.google.com/p/goauth2/oauth"
@@ -29,11 +34,63 @@ const (
 	// DefaultFilename is the default file used if none is specified in the URL.
 	DefaultFilename = "index.html"
 
+	// DefaultEmbedWidth is the width returned from the oEmbed endpoint.
+	DefaultEmbedWidth = 600
+
 	// DefaultEmbedHeight is the height returned from the oEmbed endpoint.
 	DefaultEmbedHeight = 300
 
+	// DefaultEmbedCacheAge is the number of seconds a consumer should cache an oEmbed.
+	// Zero omits the cache age from the response.
+	DefaultEmbedCacheAge = 0
+
 	// EmbedCacheAge is the number of seconds a consumer should cache an oEmbed.
-	EmbedCacheAge = 0
+	//
+	// Deprecated: Use DefaultEmbedCacheAge or Handler.EmbedCacheAge.
+	EmbedCacheAge = DefaultEmbedCacheAge
+
+	// ImmutableCacheControl is the Cache-Control header sent for pinned revisions.
+	ImmutableCacheControl = "public, max-age=31536000, immutable"
+
+	// PrivateCacheControl is the Cache-Control header sent to signed in users
+	// so that reloaded gists are visible immediately.
+	PrivateCacheControl = "private, no-cache"
+)
+
+const (
+	// DefaultContentSecurityPolicy is sent with gist files. The sandbox gives
+	// hosted pages a unique origin so scripts cannot access the application's
+	// cookies or storage. Any site can frame a gist so it can be embedded.
+	DefaultContentSecurityPolicy = "sandbox allow-scripts allow-forms allow-popups allow-modals; frame-ancestors *"
+
+	// ContentOriginSecurityPolicy replaces the default policy on a separate
+	// content origin. The origin holds no cookies so pages keep their origin
+	// and can fetch the other files in their gist.
+	ContentOriginSecurityPolicy = "sandbox allow-scripts allow-same-origin allow-forms allow-popups allow-modals; frame-ancestors *"
+
+	// AppContentSecurityPolicy is sent with application pages, such as the
+	// dashboard, so they cannot be framed by other sites.
+	AppContentSecurityPolicy = "frame-ancestors 'none'"
+)
+
+const (
+	// DefaultCacheMaxAge is the number of seconds a gist file can be cached.
+	DefaultCacheMaxAge = 60
+
+	// DefaultCacheStaleWhileRevalidate is the number of seconds a stale gist
+	// file can be served while it is revalidated in the background.
+	DefaultCacheStaleWhileRevalidate = 3600
+)
+
+const (
+	// DefaultReloadInterval is the minimum time between reloads of a gist.
+	DefaultReloadInterval = 1 * time.Second
+
+	// DefaultUserReloadInterval is the time for a user to earn another reload.
+	DefaultUserReloadInterval = 5 * time.Second
+
+	// DefaultUserReloadBurst is the number of reloads a user can perform at once.
+	DefaultUserReloadBurst = 10
 )
 
 // Handler represents the root HTTP handler for the application.
@@ -49,9 +106,55 @@ type Handler struct {
 	// ExchangeFunc processes a returned OAuth2 code into a token.
 	// This function is used for testing.
 	ExchangeFunc func(string) (*oauth.Token, error)
+
+	// ReloadInterval is the minimum time between reloads of a single gist.
+	// Requests within the interval are served from the disk cache.
+	ReloadInterval time.Duration
+
+	// UserReloadInterval and UserReloadBurst limit reloads per user using a
+	// token bucket. A user earns a reload every interval up to the burst size.
+	// Requests without a reload available are served from the disk cache.
+	UserReloadInterval time.Duration
+	UserReloadBurst    int
+
+	// CachePolicy is the default caching policy for gist files. Gists can
+	// override it in their configuration file. Pinned revisions are always
+	// cached as immutable.
+	CachePolicy CachePolicy
+
+	// EmbedCacheAge is the number of seconds a consumer should cache an oEmbed.
+	EmbedCacheAge int
+
+	// ContentOrigin is the origin that gist files are served from, such as
+	// "https://usercontent.example.com". Only gist files are served on the
+	// content origin and gist requests on the app origin are redirected to it.
+	// If blank then gist files are served from the app origin.
+	ContentOrigin string
+
+	// ContentSecurityPolicy is the Content-Security-Policy sent with gist files.
+	// The default policy is sent as ContentOriginSecurityPolicy on the content
+	// origin.
+	ContentSecurityPolicy string
+
+	// AppOrigin is the origin of the application pages, such as
+	// "https://gist.example.com". Defaults to the origin of the request.
+	// It must be set if gist files are served from a separate content origin.
+	AppOrigin string
+
+	// OEmbedDiscovery adds oEmbed discovery links to served HTML gist files.
+	OEmbedDiscovery bool
+
+	// EmbedResize adds a script to served HTML gist files that reports their
+	// height to the embedding page. oEmbed HTML includes a script that
+	// listens for the height and resizes the iframe to fit.
+	EmbedResize bool
+
+	limiter reloadLimiter
+	pages   pageCache
 }
 
 // NewHandler returns a new instance of Handler.
+// GitHub endpoints are taken from the database's GitHub configuration.
 func NewHandler(db *DB, token, secret string) *Handler {
 	h := &Handler{
 		db: db,
@@ -59,12 +162,24 @@ func NewHandler(db *DB, token, secret string) *Handler {
 			ClientId:     token,
 			ClientSecret: secret,
 			Scope:        "",
-			AuthURL:      "https://github.com/login/oauth/authorize",
-			TokenURL:     "https://github.com/login/oauth/access_token",
+			AuthURL:      db.GitHub.authURL(),
+			TokenURL:     db.GitHub.tokenURL(),
 		},
 		Store:           sessions.NewCookieStore(db.secret),
-		NewGitHubClient: NewGitHubClient,
+		NewGitHubClient: db.GitHub.NewClient,
 		Logger:          log.New(os.Stderr, "", log.LstdFlags),
+
+		ReloadInterval:     DefaultReloadInterval,
+		UserReloadInterval: DefaultUserReloadInterval,
+		UserReloadBurst:    DefaultUserReloadBurst,
+
+		CachePolicy: CachePolicy{
+			MaxAge:               DefaultCacheMaxAge,
+			StaleWhileRevalidate: DefaultCacheStaleWhileRevalidate,
+		},
+		EmbedCacheAge: DefaultEmbedCacheAge,
+
+		ContentSecurityPolicy: DefaultContentSecurityPolicy,
 	}
 	h.ExchangeFunc = h.exchangeFunc
 	return h
@@ -84,6 +199,23 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Record the start time.
 	t := time.Now()
 
+	// Never let browsers guess a content type that differs from the header.
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	// The content origin only serves gist files and the script added to them.
+	if h.isContentOrigin(r) {
+		if r.URL.Path == "/_/resize.js" {
+			h.HandleScript(w, r, resizeJS())
+		} else {
+			h.HandleGist(w, r)
+		}
+		h.log(r, &t)
+		return
+	}
+
+	// Application pages cannot be framed. Gist files set their own policy.
+	w.Header().Set("Content-Security-Policy", AppContentSecurityPolicy)
+
 	// Route to the appropriate handlers.
 	switch r.URL.Path {
 	case "/":
@@ -96,14 +228,26 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.HandleLoginCallback(w, r)
 	case "/_/logout":
 		h.HandleLogout(w, r)
-	case "/oembed", "/oembed/", "/oembed.xml":
+	case "/oembed", "/oembed/":
 		h.HandleOEmbed(w, r)
+	case "/oembed.xml":
+		h.HandleOEmbedXML(w, r)
 	case "/oembed.json":
 		h.HandleOEmbedJSON(w, r)
+	case "/_/embed.js":
+		h.HandleScript(w, r, embedJS())
+	case "/_/resize.js":
+		h.HandleScript(w, r, resizeJS())
+	case "/_/code.css":
+		h.HandleStylesheet(w, r, codeStylesheet)
 	case "/logo.png":
 		_, _ = w.Write(logo())
 	default:
-		h.HandleGist(w, r)
+		if _, _, ok := ParseScriptPath(r.URL.Path, h.db.GistExists); ok {
+			h.HandleGistScript(w, r)
+		} else {
+			h.HandleGist(w, r)
+		}
 	}
 
 	// Write to access log.
@@ -115,6 +259,30 @@ func (h *Handler) log(r *http.Request, t *time.Time) {
 	h.Logger.Printf(`%s %s %q %q`+"\n", r.Method, r.RequestURI, r.Referer(), r.UserAgent())
 }
 
+// isContentOrigin returns true if the request was made to the content origin.
+func (h *Handler) isContentOrigin(r *http.Request) bool {
+	if h.ContentOrigin == "" {
+		return false
+	}
+	u, err := url.Parse(h.ContentOrigin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// contentURL returns u with its origin changed to the content origin.
+// Returns u unchanged if there is no separate content origin.
+func (h *Handler) contentURL(u *url.URL) *url.URL {
+	if h.ContentOrigin == "" {
+		return u
+	}
+	origin, err := url.Parse(h.ContentOrigin)
+	if err != nil {
+		return u
+	}
+	other := *u
+	other.Scheme, other.Host = origin.Scheme, origin.Host
+	return &other
+}
+
 // Session returns the current session.
 func (h *Handler) Session(r *http.Request) *Session {
 	s, _ := h.Store.Get(r, "default")
@@ -159,17 +327,43 @@ func (h *Handler) HandleDashboard(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 
-	// Retrieve available gists from GitHub.
+	// Retrieve the requested page of available gists from GitHub. Pages are
+	// cached so an unchanged page is not downloaded again.
+	page, _ := strconv.Atoi(r.FormValue("page"))
+	if page < 1 {
+		page = 1
+	}
+	var cached *GistList
+	_ = h.db.View(func(tx *Tx) (err error) {
+		cached, err = tx.GistList(session.UserID(), page)
+		return
+	})
+	var etag, lastModified string
+	if cached != nil {
+		etag, lastModified = cached.ETag, cached.LastModified
+	}
+
 	client := h.NewGitHubClient(user.AccessToken)
-	recent, err := client.Gists("")
-	if err != nil {
+	list, err := client.GistsPageIfModified("", page, etag, lastModified)
+	if err == ErrNotModified {
+		list, err = cached, nil
+	} else if err == nil {
+		if err := h.db.Update(func(tx *Tx) error { return tx.SaveGistList(session.UserID(), page, list) }); err != nil {
+			h.Logger.Println("save gist list:", err)
+		}
+	}
+	if err, ok := err.(*RateLimitError); ok {
+		h.Logger.Println("github gists:", err)
+		http.Error(w, "GitHub is throttling us, please try again after "+err.Reset.Format(time.Kitchen), http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
 		h.Logger.Println("github gists:", err)
 		http.Error(w, "github api error", http.StatusInternalServerError)
 		return
 	}
 
 	// Write gists out.
-	_ = (&tmpl{}).Dashboard(w, hosted, recent)
+	_ = (&tmpl{}).Dashboard(w, hosted, list.Gists, page, list.Next)
 }
 
 // HandleLogin redirects the user to GitHub OAuth2 authorization.
@@ -247,56 +441,131 @@ func (h *Handler) HandleLogout(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusFound)
 }
 
-// HandleOEmbed provides an oEmbed endpoint.
+// HandleScript serves a static script.
+func (h *Handler) HandleScript(w http.ResponseWriter, r *http.Request, b []byte) {
+	serveAsset(w, r, "application/javascript; charset=utf-8", b)
+}
+
+// HandleStylesheet serves a static stylesheet.
+func (h *Handler) HandleStylesheet(w http.ResponseWriter, r *http.Request, b []byte) {
+	serveAsset(w, r, "text/css; charset=utf-8", b)
+}
+
+// serveAsset serves a static asset. Assets change with the application, not
+// the gists, so they always use the default cache policy.
+func serveAsset(w http.ResponseWriter, r *http.Request, contentType string, b []byte) {
+	policy := CachePolicy{MaxAge: DefaultCacheMaxAge, StaleWhileRevalidate: DefaultCacheStaleWhileRevalidate}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", policy.CacheControl())
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b))
+}
+
+// HandleOEmbed provides an oEmbed endpoint. The format is selected by the
+// "format" parameter and defaults to JSON.
 func (h *Handler) HandleOEmbed(w http.ResponseWriter, r *http.Request) {
 	switch r.FormValue("format") {
-	case "json":
+	case "", "json":
 		h.HandleOEmbedJSON(w, r)
+	case "xml":
+		h.HandleOEmbedXML(w, r)
 	default:
 		w.WriteHeader(http.StatusNotImplemented)
 	}
 }
 
-// HandleOEmbedJSON provides an oEmbed endpoint.
+// HandleOEmbedJSON provides an oEmbed endpoint in the JSON format.
 func (h *Handler) HandleOEmbedJSON(w http.ResponseWriter, r *http.Request) {
-	type response struct {
-		Version      string `json:"version"`
-		Type         string `json:"type"`
-		HTML         string `json:"html"`
-		Width        int    `json:"width,omitempty"`
-		Height       int    `json:"height,omitempty"`
-		Title        string `json:"title,omitempty"`
-		CacheAge     int    `json:"cache_age,omitempty"`
-		ProviderName string `json:"provider_name,omitempty"`
-		ProviderURL  string `json:"provider_url,omitempty"`
-	}
-
-	// Retrieve URL parameter and parse.
+	resp := h.oEmbed(w, r)
+	if resp == nil {
+		return
+	}
+
+	// Write out the JSON-encoded response.
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Logger.Println("json:", err)
+	}
+}
+
+// HandleOEmbedXML provides an oEmbed endpoint in the XML format.
+func (h *Handler) HandleOEmbedXML(w http.ResponseWriter, r *http.Request) {
+	resp := h.oEmbed(w, r)
+	if resp == nil {
+		return
+	}
+
+	// Write out the XML-encoded response.
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	_, _ = io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(resp); err != nil {
+		h.Logger.Println("xml:", err)
+	}
+}
+
+// oEmbedResponse represents an oEmbed response in either format.
+type oEmbedResponse struct {
+	XMLName      xml.Name `json:"-" xml:"oembed"`
+	Version      string   `json:"version" xml:"version"`
+	Type         string   `json:"type" xml:"type"`
+	HTML         string   `json:"html" xml:"html"`
+	Width        int      `json:"width,omitempty" xml:"width,omitempty"`
+	Height       int      `json:"height,omitempty" xml:"height,omitempty"`
+	Title        string   `json:"title,omitempty" xml:"title,omitempty"`
+	CacheAge     int      `json:"cache_age,omitempty" xml:"cache_age,omitempty"`
+	ProviderName string   `json:"provider_name,omitempty" xml:"provider_name,omitempty"`
+	ProviderURL  string   `json:"provider_url,omitempty" xml:"provider_url,omitempty"`
+}
+
+// oEmbed returns the oEmbed response for the requested URL. If the response
+// cannot be generated then an error is written and nil is returned.
+func (h *Handler) oEmbed(w http.ResponseWriter, r *http.Request) *oEmbedResponse {
+	// Retrieve URL parameter and parse. There is no response for a bad URL.
 	u, err := url.Parse(r.FormValue("url"))
 	if err != nil {
 		h.Logger.Printf("oembed: %s", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		http.NotFound(w, r)
+		return nil
 	}
 	q := u.Query()
 
-	// Retrieve width & height.
-	width, height := 0, DefaultEmbedHeight
+	// Retrieve the consumer's maximum size.
+	var maxWidth, maxHeight int
+	for _, p := range []struct {
+		name string
+		v    *int
+	}{{"maxwidth", &maxWidth}, {"maxheight", &maxHeight}} {
+		if s := r.FormValue(p.name); s != "" {
+			if *p.v, err = strconv.Atoi(s); err != nil || *p.v <= 0 {
+				http.Error(w, "invalid "+p.name, http.StatusBadRequest)
+				return nil
+			}
+		}
+	}
+
+	// Retrieve width & height and constrain them to the maximum size.
+	width, height := DefaultEmbedWidth, DefaultEmbedHeight
 	if v, _ := strconv.Atoi(q.Get("width")); v > 0 {
 		width = v
 	}
 	if v, _ := strconv.Atoi(q.Get("height")); v > 0 {
 		height = v
 	}
+	if maxWidth > 0 && width > maxWidth {
+		width = maxWidth
+	}
+	if maxHeight > 0 && height > maxHeight {
+		height = maxHeight
+	}
 
-	// Extract gist id.
-	gistID, _, err := ParsePath(u.Path)
+	// Extract gist id. The code view of a file can also be embedded.
+	path, _ := ParseViewPath(u.Path)
+	gistID, _, _, err := ParsePath(path, h.db.GistExists)
 	if err == errNonCanonicalPath {
 		u.Path += "/"
 	} else if err != nil {
 		h.Logger.Printf("oembed: parse path: %s", err)
 		http.NotFound(w, r)
-		return
+		return nil
 	}
 
 	// Retrieve gist.
@@ -308,46 +577,59 @@ func (h *Handler) HandleOEmbedJSON(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		h.Logger.Printf("oembed: %s", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
+		return nil
 	} else if gist == nil {
 		h.Logger.Printf("oembed: not found: %s", gistID)
 		http.NotFound(w, r)
-		return
+		return nil
+	} else if !gist.Public {
+		h.Logger.Printf("oembed: private gist: %s", gistID)
+		http.Error(w, "private gist", http.StatusUnauthorized)
+		return nil
 	}
 
 	// Construct an oEmbed response.
-	resp := &response{
+	resp := &oEmbedResponse{
 		Version:      "1.0",
 		Type:         "rich",
 		Width:        width,
 		Height:       height,
 		Title:        gist.Description,
-		CacheAge:     EmbedCacheAge,
+		CacheAge:     h.EmbedCacheAge,
 		ProviderName: "Gist Exposed!",
 		ProviderURL:  "https://gist.exposed",
 	}
 
 	// Set HTML.
 	var buf bytes.Buffer
-	_, _ = buf.WriteString(`<div class="gist-exposed" style="position: relative; padding-bottom: ` + strconv.Itoa(height) + `; padding-top: 0px; height: 0; overflow: hidden;">`)
-	_, _ = buf.WriteString(`<iframe style="position: absolute; top:0; left: 0; width: 100%; height: 100%; border: none;" src="` + html.EscapeString(u.String()) + `"></iframe>`)
+	var maxHeightAttr string
+	if h.EmbedResize && maxHeight > 0 {
+		maxHeightAttr = ` data-max-height="` + strconv.Itoa(maxHeight) + `"`
+	}
+	_, _ = buf.WriteString(`<div class="gist-exposed" style="position: relative; padding-bottom: ` + strconv.Itoa(height) + `px; padding-top: 0px; height: 0; overflow: hidden; max-width: ` + strconv.Itoa(width) + `px;"` + maxHeightAttr + `>`)
+	_, _ = buf.WriteString(`<iframe style="position: absolute; top:0; left: 0; width: 100%; height: 100%; border: none;" src="` + html.EscapeString(h.contentURL(u).String()) + `"></iframe>`)
 	_, _ = buf.WriteString(`</div>`)
-	resp.HTML = buf.String()
 
-	// Write out the JSON-encoded response.
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		h.Logger.Println("json:", err)
+	// Resize the iframe to the height reported by the hosted page.
+	if h.EmbedResize {
+		_, _ = buf.WriteString(`<script async src="` + html.EscapeString(h.appOrigin(r)+"/_/embed.js") + `"></script>`)
 	}
+	resp.HTML = buf.String()
+
+	return resp
 }
 
 // HandleGist serves a single file for a gist.
 // If the root is requested then the gist content is refreshed.
+// If a revision is specified then the file is served from that revision.
+// If the code view is requested then the file is served as highlighted HTML.
 func (h *Handler) HandleGist(w http.ResponseWriter, r *http.Request) {
 	session := h.Session(r)
 
 	// Extract the path variables.
-	gistID, filename, err := ParsePath(r.URL.Path)
+	path, codeView := ParseViewPath(r.URL.Path)
+	codeView = codeView || r.FormValue("view") == "code"
+	gistID, revision, filename, err := ParsePath(path, h.db.GistExists)
 	if err == errNonCanonicalPath {
 		u := r.URL
 		u.Path += "/"
@@ -359,9 +641,9 @@ func (h *Handler) HandleGist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set default filename.
-	if filename == "" {
-		filename = DefaultFilename
+	// Set default filename for the root and nested directories.
+	if filename == "" || strings.HasSuffix(filename, "/") {
+		filename += DefaultFilename
 	}
 
 	// Parse referrer.
@@ -372,36 +654,407 @@ func (h *Handler) HandleGist(w http.ResponseWriter, r *http.Request) {
 	//   1. User is logged in.
 	//   2. User is loading an HTML page.
 	//   3. User is loading page directly (i.e. not in an iframe).
+	//   4. User is not loading a pinned revision.
 	//
 	reload := true
 	reload = reload && session.Authenticated()
 	reload = reload && filepath.Ext(filename) == ".html"
 	reload = reload && (r.Referer() == "" || referrer.Host == r.Host)
+	reload = reload && revision == ""
+
+	// Serve from the disk cache if reloads are being throttled.
+	if reload && !h.allowReload(session.UserID(), gistID) {
+		h.Logger.Printf("reload throttled: user=%d gist=%s", session.UserID(), gistID)
+		reload = false
+	}
 
-	// Update gist.
+	// Update gist. If GitHub is throttling requests then serve the cached copy.
 	if reload {
 		if err := h.db.LoadGist(session.UserID(), gistID); err != nil {
 			h.Logger.Printf("reload gist: %s", err)
-			http.Error(w, "error loading gist", http.StatusInternalServerError)
+
+			// Throttled reloads serve the cached copy if there is one.
+			if rerr, ok := err.(*RateLimitError); ok && !h.db.GistExists(gistID) {
+				if d := time.Until(rerr.Reset); d > 0 {
+					w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.Seconds()))))
+				}
+				http.Error(w, "GitHub is throttling us, try again later", http.StatusServiceUnavailable)
+				return
+			} else if ok {
+				w.Header().Set("Warning", `110 - "GitHub is throttling us, serving cached copy"`)
+			} else if err, ok := err.(*SizeLimitError); ok {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			} else {
+				http.Error(w, "error loading gist", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	// Gist files are only served from the content origin, if there is one,
+	// so hosted pages cannot act on behalf of the signed in user.
+	if h.ContentOrigin != "" && !h.isContentOrigin(r) {
+		http.Redirect(w, r, h.contentURL(r.URL).String(), http.StatusFound)
+		return
+	}
+
+	// Find the file in the gist's manifest.
+	g, file, err := h.db.GistFile(gistID, revision, filename)
+	if err != nil {
+		h.Logger.Printf("gist file: %s", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	} else if file == nil {
+		h.Logger.Printf("gist file not found: %s/%s", gistID, filename)
+		http.NotFound(w, r)
+		return
+	}
+
+	// Modified pages are only stored if their markup is the same for every
+	// request for the file. Otherwise the request could pick the markup.
+	// Discovery links use the canonical file URL unless there is a query or
+	// no fixed app origin, in which case they follow the request.
+	stored := !codeView && (!h.OEmbedDiscovery || (h.AppOrigin != "" && r.URL.RawQuery == ""))
+
+	// HTML pages advertise the oEmbed endpoint so consumers can discover it
+	// and report their height so embeds can be resized to fit.
+	var head string
+	if isHTML(filename) || codeView {
+		if h.OEmbedDiscovery {
+			page := h.appOrigin(r) + gistPath(gistID, revision, filename)
+			if !stored {
+				page = h.appOrigin(r) + r.URL.Path
+				if r.URL.RawQuery != "" {
+					page += "?" + r.URL.RawQuery
+				}
+			}
+			head += h.oEmbedLinks(r, page)
+		}
+		if h.EmbedResize {
+			head += `<script async src="/_/resize.js"></script>`
+		}
+	}
+
+	// Stored pages are served from a blob of the modified page so they can be
+	// precompressed like any other file.
+	hash := file.Hash
+	if head != "" && stored {
+		if hash, err = h.pageBlob(file, head); err != nil {
+			h.Logger.Printf("insert head: %s/%s: %s", gistID, filename, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Serve a precompressed variant of text files if the client accepts one.
+	var f *os.File
+	var encoding string
+	if Compressible(filename) {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	if Compressible(filename) && (head == "" || hash != file.Hash) {
+		for _, enc := range AcceptedEncodings(r.Header.Get("Accept-Encoding")) {
+			if f, err = h.db.OpenCompressedBlob(hash, enc); err == nil {
+				encoding = enc
+				break
+			}
+		}
+	}
+
+	// Otherwise serve the gist file from disk cache.
+	if f == nil {
+		if f, err = h.db.OpenBlob(hash); err != nil {
+			h.Logger.Printf("read gist: %s/%s: %s", gistID, filename, err)
+			http.NotFound(w, r)
 			return
 		}
 	}
+	defer func() { _ = f.Close() }()
+
+	// Set the content type from the extension so it is never sniffed from
+	// the content. Unknown types are served as opaque bytes.
+	w.Header().Set("Content-Type", contentType(filename))
 
-	// Serve gist file from disk cache.
-	path := h.db.GistFilePath(gistID, filename)
-	f, err := os.Open(path)
+	// Blobs are addressed by content so the hash is a strong validator.
+	// Each encoding is a different representation so it has its own tag.
+	// The inserted markup depends on the configuration and the host so it
+	// is also part of the tag.
+	etag := file.Hash
+	if head != "" {
+		etag += fmt.Sprintf("-%08x", crc32.ChecksumIEEE([]byte(head)))
+	}
+	var content io.ReadSeeker = f
+	switch {
+	case codeView:
+		b, err := ioutil.ReadAll(f)
+		if err != nil {
+			h.Logger.Printf("read gist: %s/%s: %s", gistID, filename, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		cf := &codeFile{Filename: file.Filename, URL: h.gistFileURL(r, gistID, revision, file.Filename), Anchor: "L", Content: b}
+		content = bytes.NewReader(insertHead(codeViewPage(cf), head))
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("ETag", fmt.Sprintf(`"%s-code-%08x"`, file.Hash, crc32.ChecksumIEEE([]byte(head))))
+	case encoding != "":
+		w.Header().Set("Content-Encoding", encoding)
+		w.Header().Set("ETag", `"`+etag+"-"+encoding+`"`)
+	case head != "" && hash == file.Hash:
+		b, err := ioutil.ReadAll(f)
+		if err != nil {
+			h.Logger.Printf("read gist: %s/%s: %s", gistID, filename, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(insertHead(b, head))
+		w.Header().Set("ETag", `"`+etag+`"`)
+	default:
+		w.Header().Set("ETag", `"`+etag+`"`)
+	}
+
+	// Sandbox hosted pages and set who can frame them.
+	if h.ContentSecurityPolicy == DefaultContentSecurityPolicy && h.isContentOrigin(r) {
+		w.Header().Set("Content-Security-Policy", ContentOriginSecurityPolicy)
+	} else if h.ContentSecurityPolicy != "" {
+		w.Header().Set("Content-Security-Policy", h.ContentSecurityPolicy)
+	} else {
+		w.Header().Del("Content-Security-Policy")
+	}
+
+	// Set the caching policy. Signed in users skip caches since they can reload.
+	// Without a content origin the session cookie is sent with each request
+	// so shared caches must not give anonymous responses to signed in users.
+	w.Header().Set("Cache-Control", h.cacheControl(g, revision, session.Authenticated()))
+	if h.ContentOrigin == "" {
+		w.Header().Add("Vary", "Cookie")
+	}
+
+	// Serve the file with support for conditional, range and HEAD requests.
+	http.ServeContent(w, r, filename, g.SyncedAt, content)
+}
+
+// contentType returns the MIME type for a filename based on its extension.
+// Returns "application/octet-stream" if the extension is not recognized.
+func contentType(filename string) string {
+	if typ := mime.TypeByExtension(filepath.Ext(filename)); typ != "" {
+		return typ
+	}
+	return "application/octet-stream"
+}
+
+// cacheControl returns the Cache-Control header for a gist. Pinned revisions
+// never change so they can be cached indefinitely.
+func (h *Handler) cacheControl(g *Gist, revision string, private bool) string {
+	switch {
+	case revision != "":
+		return ImmutableCacheControl
+	case private:
+		return PrivateCacheControl
+	case g.Config != nil && g.Config.Cache != nil:
+		return g.Config.Cache.CacheControl()
+	default:
+		return h.CachePolicy.CacheControl()
+	}
+}
+
+// HandleGistScript serves a script that writes a gist into the page, for
+// sites that allow script tags but not iframes. A single file is written if
+// the "file" parameter is set. Gists are never reloaded from an embed.
+func (h *Handler) HandleGistScript(w http.ResponseWriter, r *http.Request) {
+	gistID, revision, ok := ParseScriptPath(r.URL.Path, h.db.GistExists)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Retrieve gist.
+	g, err := h.db.Gist(gistID, revision)
 	if err != nil {
-		h.Logger.Printf("read gist: %s: %s", path, err)
+		h.Logger.Printf("gist script: %s", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	} else if g == nil {
+		h.Logger.Printf("gist script: not found: %s", gistID)
 		http.NotFound(w, r)
 		return
+	} else if !g.Public {
+		h.Logger.Printf("gist script: private gist: %s", gistID)
+		http.Error(w, "private gist", http.StatusUnauthorized)
+		return
+	}
+
+	// Select the requested file or all files other than the configuration.
+	var files []*GistFile
+	if name := r.FormValue("file"); name != "" {
+		f := g.File(h.db.gistFilename(name))
+		if f == nil {
+			h.Logger.Printf("gist script: file not found: %s/%s", gistID, name)
+			http.NotFound(w, r)
+			return
+		}
+		files = append(files, f)
+	} else {
+		for _, f := range g.Files {
+			if f.Filename != GistConfigFilename {
+				files = append(files, f)
+			}
+		}
+	}
+
+	// Read each file from the disk cache.
+	var a []*codeFile
+	for _, f := range files {
+		b, err := h.readBlob(f.Hash)
+		if err != nil {
+			h.Logger.Printf("gist script: read: %s/%s: %s", gistID, f.Filename, err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		a = append(a, &codeFile{Filename: f.Filename, URL: h.gistFileURL(r, gistID, revision, f.Filename), Anchor: anchorPrefix(f.Filename), Content: b})
+	}
+
+	// Render the code view and write it out along with the stylesheet.
+	var view bytes.Buffer
+	writeCodeView(&view, a)
+	stylesheet := `<link rel="stylesheet" href="` + html.EscapeString(h.appOrigin(r)+"/_/code.css") + `">`
+
+	var buf bytes.Buffer
+	for _, s := range []string{stylesheet, view.String()} {
+		// JSON strings are valid JavaScript and HTML characters are escaped
+		// so the script cannot be closed early.
+		b, _ := json.Marshal(s)
+		_, _ = fmt.Fprintf(&buf, "document.write(%s);\n", b)
+	}
+
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", h.cacheControl(g, revision, false))
+	http.ServeContent(w, r, "", g.SyncedAt, bytes.NewReader(buf.Bytes()))
+}
+
+// readBlob returns the contents of a blob.
+func (h *Handler) readBlob(hash string) ([]byte, error) {
+	f, err := h.db.OpenBlob(hash)
+	if err != nil {
+		return nil, err
 	}
 	defer func() { _ = f.Close() }()
+	return ioutil.ReadAll(f)
+}
+
+// gistFileURL returns the URL of a gist file on the content origin, if there
+// is one, or the application origin.
+func (h *Handler) gistFileURL(r *http.Request, gistID, revision, filename string) string {
+	origin := h.appOrigin(r)
+	if h.ContentOrigin != "" {
+		origin = strings.TrimSuffix(h.ContentOrigin, "/")
+	}
+
+	return origin + gistPath(gistID, revision, filename)
+}
+
+// gistPath returns the escaped canonical path of a gist file.
+func gistPath(gistID, revision, filename string) string {
+	path := "/" + gistID + "/"
+	if revision != "" {
+		path += revision + "/"
+	}
+	return (&url.URL{Path: path + filename}).EscapedPath()
+}
+
+// oEmbedLinks returns the oEmbed discovery links for a page URL.
+func (h *Handler) oEmbedLinks(r *http.Request, page string) string {
+	q := url.Values{"url": {page}}.Encode()
+	return `<link rel="alternate" type="application/json+oembed" href="` + html.EscapeString(h.appOrigin(r)+"/oembed.json?"+q) + `">` +
+		`<link rel="alternate" type="text/xml+oembed" href="` + html.EscapeString(h.appOrigin(r)+"/oembed.xml?"+q) + `">`
+}
+
+// appOrigin returns the origin of the application pages. Defaults to the
+// origin of the request.
+func (h *Handler) appOrigin(r *http.Request) string {
+	if h.AppOrigin != "" {
+		return strings.TrimSuffix(h.AppOrigin, "/")
+	} else if r.TLS != nil {
+		return "https://" + r.Host
+	}
+	return "http://" + r.Host
+}
 
-	// Set the content type.
-	w.Header().Set("Content-Type", mime.TypeByExtension(filepath.Ext(filename)))
+// insertHead inserts s at the end of the document's head. If there is no
+// closing head tag then s is inserted after the opening head tag, or after
+// the doctype, so the document stays in standards mode.
+func insertHead(b []byte, s string) []byte {
+	i := indexFold(b, "</head>")
+	if i == -1 {
+		i = endOfTag(b, "<head")
+	}
+	if i == -1 {
+		i = endOfTag(b, "<!doctype")
+	}
+	if i == -1 {
+		i = 0
+	}
+
+	other := make([]byte, 0, len(b)+len(s))
+	other = append(other, b[:i]...)
+	other = append(other, s...)
+	return append(other, b[i:]...)
+}
+
+// endOfTag returns the index after the first tag named by prefix, such as
+// "<head". Returns -1 if there is no such tag.
+func endOfTag(b []byte, prefix string) int {
+	for off := 0; ; {
+		i := indexFold(b[off:], prefix)
+		if i == -1 {
+			return -1
+		}
+		i += off + len(prefix)
+
+		// Skip longer tag names, such as "<header>".
+		if i < len(b) && (b[i] == '>' || b[i] == '/' || b[i] == ' ' || b[i] == '\t' || b[i] == '\n' || b[i] == '\r' || b[i] == '\f') {
+			if j := bytes.IndexByte(b[i:], '>'); j != -1 {
+				return i + j + 1
+			}
+			return -1
+		}
+		off = i
+	}
+}
 
-	// Copy the file to the response.
-	_, _ = io.Copy(w, f)
+// indexFold returns the index of the first instance of s in b, ignoring the
+// case of ASCII letters. s must be lowercase. Returns -1 if s is not present.
+func indexFold(b []byte, s string) int {
+	for i := 0; i+len(s) <= len(b); i++ {
+		j := 0
+		for ; j < len(s); j++ {
+			ch := b[i+j]
+			if ch >= 'A' && ch <= 'Z' {
+				ch += 'a' - 'A'
+			}
+			if ch != s[j] {
+				break
+			}
+		}
+		if j == len(s) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isHTML returns true if the filename has an HTML extension.
+func isHTML(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".html", ".htm":
+		return true
+	}
+	return false
+}
+
+// allowReload returns true if the user can reload the gist from GitHub.
+func (h *Handler) allowReload(userID int, gistID string) bool {
+	return h.limiter.allow(userID, gistID, h.ReloadInterval, h.UserReloadInterval, h.UserReloadBurst, time.Now())
 }
 
 func (h *Handler) exchange(code string) (*oauth.Token, error) {
@@ -413,25 +1066,241 @@ func (h *Handler) exchangeFunc(code string) (*oauth.Token, error) {
 	return t.Exchange(code)
 }
 
-// ParsePath extracts the gist id and filename from the path.
-func ParsePath(s string) (gistID, filename string, err error) {
-	a := strings.Split(s, "/")[1:]
-	switch len(a) {
-	case 1:
-		if a[0] == "" {
-			return "", "", fmt.Errorf("invalid path")
+// pageBlob returns the hash of a blob containing the file with head inserted.
+// The blob is written to the blob store on first use and its compressed
+// variants are created in the background.
+func (h *Handler) pageBlob(file *GistFile, head string) (string, error) {
+	key := fmt.Sprintf("%s-%08x", file.Hash, crc32.ChecksumIEEE([]byte(head)))
+	if other := h.pages.get(key); other != "" && h.db.BlobExists(other) {
+		return other, nil
+	}
+
+	f, err := h.db.OpenBlob(file.Hash)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	other, err := h.db.WriteBlob(bytes.NewReader(insertHead(b, head)))
+	if err != nil {
+		return "", err
+	}
+	if h.pages.set(key, other) && h.db.Precompress && file.Size >= minCompressSize {
+		go h.db.compressBlobs(other)
+	}
+	return other, nil
+}
+
+// pageCache maps pages and their inserted markup to the blobs of the
+// modified pages.
+type pageCache struct {
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+// get returns the blob hash for a key. Returns blank if there is none.
+func (c *pageCache) get(key string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hashes[key]
+}
+
+// set records the blob hash for a key. Returns true if the key is new.
+func (c *pageCache) set(key, hash string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hashes == nil {
+		c.hashes = make(map[string]string)
+	}
+	_, ok := c.hashes[key]
+	c.hashes[key] = hash
+	return !ok
+}
+
+// reloadLimiter restricts how often gists are reloaded from GitHub.
+// It tracks the last reload per gist and a token bucket per user.
+type reloadLimiter struct {
+	mu    sync.Mutex
+	gists map[string]time.Time
+	users map[int]*tokenBucket
+}
+
+// tokenBucket represents the reloads available to a single user.
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// allow returns true and records the reload if neither the gist nor the
+// user limit has been reached. A zero interval disables the limit.
+func (l *reloadLimiter) allow(userID int, gistID string, interval, userInterval time.Duration, burst int, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.gists == nil {
+		l.gists = make(map[string]time.Time)
+		l.users = make(map[int]*tokenBucket)
+	}
+
+	// Check the time since the gist's last reload.
+	if interval > 0 {
+		if t, ok := l.gists[gistID]; ok && now.Sub(t) < interval {
+			return false
+		}
+	}
+
+	// Refill the user's bucket and take a token.
+	if userInterval > 0 {
+		b := l.users[userID]
+		if b == nil {
+			b = &tokenBucket{tokens: float64(burst), updatedAt: now}
+			l.users[userID] = b
 		}
-		return a[0], "", errNonCanonicalPath
-	case 2:
-		if strings.Contains(a[1], ".") || a[1] == "" {
-			return a[0], a[1], nil
+		b.tokens += float64(now.Sub(b.updatedAt)) / float64(userInterval)
+		if b.tokens > float64(burst) {
+			b.tokens = float64(burst)
 		}
-		return a[1], "", errNonCanonicalPath
-	case 3:
-		return a[1], a[2], nil
+		b.updatedAt = now
+
+		if b.tokens < 1 {
+			return false
+		}
+		b.tokens--
+	}
+
+	// Remove expired gist entries so the map does not grow unbounded.
+	for id, t := range l.gists {
+		if now.Sub(t) >= interval {
+			delete(l.gists, id)
+		}
+	}
+	if interval > 0 {
+		l.gists[gistID] = now
+	}
+
+	return true
+}
+
+// ParsePath extracts the gist id, revision and filename from the path.
+// The revision is only set when the path is pinned to a specific revision.
+//
+// The filename may be a nested path such as "css/style.css". Without a
+// revision, the known gists decide between "/<user>/<id>/<path>" and
+// "/<id>/<path>" so directory names are never mistaken for gist IDs: the
+// second segment is used if it is a known gist, otherwise the first segment
+// is used if it is a known gist. Only paths to unknown gists, which are
+// loaded on first use, fall back to reading a valid second segment as the
+// gist ID. If exists is nil then no gists are known.
+func ParsePath(s string, exists func(gistID string) bool) (gistID, revision, filename string, err error) {
+	a := strings.Split(s, "/")[1:]
+	var user []string // set if the path starts with a username
+	var rest []string
+	switch {
+	case len(a) == 0 || (len(a) == 1 && a[0] == ""):
+		return "", "", "", fmt.Errorf("invalid path")
+	case len(a) == 1:
+		gistID, err = a[0], errNonCanonicalPath
+	case IsRevision(a[1]):
+		gistID, revision, rest = a[0], a[1], a[2:]
+	case len(a) > 2 && IsRevision(a[2]):
+		user, gistID, revision, rest = a[:1], a[1], a[2], a[3:]
+	case exists != nil && ValidGistID(a[1]) && exists(a[1]):
+		user, gistID, rest = a[:1], a[1], a[2:]
+	case exists != nil && ValidGistID(a[0]) && exists(a[0]):
+		gistID, rest = a[0], a[1:]
+	case ValidGistID(a[1]):
+		user, gistID, rest = a[:1], a[1], a[2:]
 	default:
-		return "", "", fmt.Errorf("invalid path: %s", s)
+		gistID, rest = a[0], a[1:]
+	}
+	if len(rest) == 0 {
+		err = errNonCanonicalPath
+	}
+
+	// Refuse IDs and filenames that could be used to escape the data directory.
+	// Only the last segment can be blank, which refers to a directory index.
+	if !ValidGistID(gistID) || (user != nil && !validUsername(user[0])) {
+		return "", "", "", fmt.Errorf("invalid path: %s", s)
+	}
+	for i, seg := range rest {
+		if !ValidFilename(seg) && !(seg == "" && i == len(rest)-1) {
+			return "", "", "", fmt.Errorf("invalid path: %s", s)
+		}
+	}
+	return gistID, revision, strings.Join(rest, "/"), err
+}
+
+// codeViewPage returns a standalone HTML page with the code view of a file.
+func codeViewPage(f *codeFile) []byte {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString(`<!DOCTYPE html><html><head><meta charset="utf-8">`)
+	_, _ = buf.WriteString(`<title>` + html.EscapeString(f.Filename) + `</title>`)
+	_, _ = buf.WriteString(`<style>` + string(codeStylesheet) + `</style>`)
+	_, _ = buf.WriteString(`</head><body class="gist-exposed-page">`)
+	writeCodeView(&buf, []*codeFile{f})
+	_, _ = buf.WriteString(`</body></html>`)
+	return buf.Bytes()
+}
+
+// ParseViewPath removes the code view prefix, "/_/view", from a path.
+// Returns true if the prefix was removed.
+func ParseViewPath(s string) (string, bool) {
+	if !strings.HasPrefix(s, "/_/view/") {
+		return s, false
+	}
+	return strings.TrimPrefix(s, "/_/view"), true
+}
+
+// ParseScriptPath parses the path of a script embed, such as "/<gistID>.js"
+// or "/<user>/<gistID>/<revision>.js". The path is split the same way as
+// ParsePath so "/<gistID>/<name>.js" is a gist file. Returns false if the path
+// does not refer to a script embed of a gist that exists reports as known.
+func ParseScriptPath(s string, exists func(gistID string) bool) (gistID, revision string, ok bool) {
+	if !strings.HasSuffix(s, ".js") || exists == nil {
+		return "", "", false
+	}
+
+	// Only the root of a gist can be embedded.
+	gistID, revision, filename, err := ParsePath(strings.TrimSuffix(s, ".js"), exists)
+	if err != errNonCanonicalPath || filename != "" || !exists(gistID) {
+		return "", "", false
+	}
+	return gistID, revision, true
+}
+
+// validUsername returns true if s is formatted as a GitHub username.
+func validUsername(s string) bool {
+	if s == "" || len(s) > 39 {
+		return false
+	}
+	for _, ch := range s {
+		if !(ch >= '0' && ch <= '9') && !(ch >= 'a' && ch <= 'z') && !(ch >= 'A' && ch <= 'Z') && ch != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsRevision returns true if s is formatted as a gist revision (a SHA-1 hash).
+func IsRevision(s string) bool {
+	return len(s) == 40 && isHex(s)
+}
+
+// isHex returns true if s is a non-blank string of lowercase hex characters.
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, ch := range s {
+		if !(ch >= '0' && ch <= '9') && !(ch >= 'a' && ch <= 'f') {
+			return false
+		}
 	}
+	return true
 }
 
 // Session represents an HTTP session.
@@ -11,8 +11,10 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"code.google.com/p/goauth2/oauth"
 	"github.com/benbjohnson/gist"
@@ -39,10 +41,11 @@ func TestHandler_Root_Authorized(t *testing.T) {
 
 	// Return a single gist.
 	client := &MockGitHubClient{
-		GistsFunc: func(username string) ([]*gist.Gist, error) {
-			return []*gist.Gist{
+		GistsPageIfModifiedFunc: func(username string, page int, etag, lastModified string) (*gist.GistList, error) {
+			equals(t, 1, page)
+			return &gist.GistList{Gists: []*gist.Gist{
 				&gist.Gist{ID: "abc", Description: "my gist"},
-			}, nil
+			}}, nil
 		},
 	}
 
@@ -58,6 +61,76 @@ func TestHandler_Root_Authorized(t *testing.T) {
 	assert(t, strings.Contains(readall(resp.Body), "my gist"), "expected substring")
 }
 
+// Ensure the dashboard reuses a cached page of gists if it is unchanged.
+func TestHandler_Dashboard_NotModified(t *testing.T) {
+	store := NewTestStore()
+	store.GetFunc = func(r *http.Request, name string) (*sessions.Session, error) {
+		return &sessions.Session{Values: map[interface{}]interface{}{"UserID": 1000}}, nil
+	}
+
+	// Return the page once and then report that it is unchanged.
+	var n int
+	client := &MockGitHubClient{
+		GistsPageIfModifiedFunc: func(username string, page int, etag, lastModified string) (*gist.GistList, error) {
+			if n++; n > 1 {
+				equals(t, `"abc"`, etag)
+				return nil, gist.ErrNotModified
+			}
+			equals(t, "", etag)
+			return &gist.GistList{Gists: []*gist.Gist{{ID: "abc", Description: "my cached gist"}}, ETag: `"abc"`}, nil
+		},
+	}
+
+	h := NewTestHandler()
+	h.Handler.Store = store
+	h.Handler.NewGitHubClient = func(_ string) gist.GitHubClient { return client }
+	defer h.Close()
+	h.DB.Update(func(tx *gist.Tx) error { return tx.SaveUser(&gist.User{ID: 1000, AccessToken: "XYZ"}) })
+
+	// The cached page is shown when GitHub reports no changes.
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(h.Server.URL + "/_/dashboard")
+		ok(t, err)
+		body := readall(resp.Body)
+		resp.Body.Close()
+		equals(t, 200, resp.StatusCode)
+		assert(t, strings.Contains(body, "my cached gist"), "unexpected body: %s", body)
+	}
+	equals(t, 2, n)
+}
+
+// Ensure the dashboard pages through the user's gists.
+func TestHandler_Dashboard_Pagination(t *testing.T) {
+	// Create an authenticated user.
+	store := NewTestStore()
+	store.GetFunc = func(r *http.Request, name string) (*sessions.Session, error) {
+		return &sessions.Session{Values: map[interface{}]interface{}{"UserID": 1000}}, nil
+	}
+
+	// Return the second of three pages.
+	client := &MockGitHubClient{
+		GistsPageIfModifiedFunc: func(username string, page int, etag, lastModified string) (*gist.GistList, error) {
+			equals(t, 2, page)
+			return &gist.GistList{Gists: []*gist.Gist{{ID: "abc", Description: "older gist"}}, Next: 3}, nil
+		},
+	}
+
+	// Setup handler.
+	h := NewTestHandler()
+	h.Handler.Store = store
+	h.Handler.NewGitHubClient = func(_ string) gist.GitHubClient { return client }
+	defer h.Close()
+
+	// Retrieve the second page.
+	resp, err := http.Get(h.Server.URL + "/_/dashboard?page=2")
+	ok(t, err)
+	body := readall(resp.Body)
+	resp.Body.Close()
+	assert(t, strings.Contains(body, "older gist"), "expected gist")
+	assert(t, strings.Contains(body, `href="/_/dashboard?page=1"`), "expected previous page link")
+	assert(t, strings.Contains(body, `href="/_/dashboard?page=3"`), "expected next page link")
+}
+
 // Ensure the user is redirected to GitHub for authorization.
 func TestHandler_Authorize(t *testing.T) {
 	// Create the mock session store.
@@ -100,6 +173,29 @@ func TestHandler_Authorize(t *testing.T) {
 	equals(t, redirectURL.Query().Get("state"), session.Values["AuthState"])
 }
 
+// Ensure the user is redirected to the configured OAuth URL for GitHub Enterprise.
+func TestHandler_Authorize_GitHubEnterprise(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+
+	// Create a handler using GitHub Enterprise endpoints.
+	h.DB.GitHub = &gist.GitHubConfig{AuthURL: "https://github.example.com/login/oauth/authorize"}
+	handler := gist.NewHandler(h.DB, "ABC", "123")
+	handler.Store = NewTestStore()
+	handler.Store.(*TestStore).GetFunc = func(r *http.Request, name string) (*sessions.Session, error) {
+		return sessions.NewSession(handler.Store, ""), nil
+	}
+	handler.Store.(*TestStore).SaveFunc = func(r *http.Request, w http.ResponseWriter, session *sessions.Session) error { return nil }
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/_/login", nil)
+	handler.HandleLogin(w, r)
+
+	redirectURL, _ := url.Parse(w.Header().Get("Location"))
+	equals(t, "github.example.com", redirectURL.Host)
+	equals(t, "/login/oauth/authorize", redirectURL.Path)
+}
+
 // Ensure the OAuth2 callback is processed correctly.
 func TestHandler_Authorized(t *testing.T) {
 	// Create the mock session store.
@@ -113,7 +209,6 @@ func TestHandler_Authorized(t *testing.T) {
 
 	// Return a fake user.
 	client := &MockGitHubClient{}
-	client.GistsFunc = func(username string) ([]*gist.Gist, error) { return nil, nil }
 	client.UserFunc = func(username string) (*gist.User, error) {
 		return &gist.User{ID: 1000, Username: "john"}, nil
 	}
@@ -163,7 +258,7 @@ func TestHandler_OEmbed(t *testing.T) {
 
 	// Create the gist in the database.
 	h.DB.Update(func(tx *gist.Tx) error {
-		return tx.SaveGist(&gist.Gist{ID: "abc123", UserID: 1000, Description: "My Gist"})
+		return tx.SaveGist(&gist.Gist{ID: "abc123", UserID: 1000, Public: true, Description: "My Gist"})
 	})
 
 	// Retrieve oEmbed.
@@ -173,8 +268,8 @@ func TestHandler_OEmbed(t *testing.T) {
 	ok(t, err)
 	equals(t, 200, resp.StatusCode)
 
-	html, _ := json.Marshal(`<div class="gist-exposed" style="position: relative; padding-bottom: 300; padding-top: 0px; height: 0; overflow: hidden;"><iframe style="position: absolute; top:0; left: 0; width: 100%; height: 100%; border: none;" src="https://gist.exposed/benbjohnson/abc123/"></iframe></div>`)
-	equals(t, `{"version":"1.0","type":"rich","html":`+string(html)+`,"height":300,"title":"My Gist","provider_name":"Gist Exposed!","provider_url":"https://gist.exposed"}`+"\n", readall(resp.Body))
+	html, _ := json.Marshal(`<div class="gist-exposed" style="position: relative; padding-bottom: 300px; padding-top: 0px; height: 0; overflow: hidden; max-width: 600px;"><iframe style="position: absolute; top:0; left: 0; width: 100%; height: 100%; border: none;" src="https://gist.exposed/benbjohnson/abc123/"></iframe></div>`)
+	equals(t, `{"version":"1.0","type":"rich","html":`+string(html)+`,"width":600,"height":300,"title":"My Gist","provider_name":"Gist Exposed!","provider_url":"https://gist.exposed"}`+"\n", readall(resp.Body))
 }
 
 // Ensure an oEmbed with width/height set is returned correctly.
@@ -184,7 +279,7 @@ func TestHandler_OEmbed_WidthHeight(t *testing.T) {
 
 	// Create the gist in the database.
 	h.DB.Update(func(tx *gist.Tx) error {
-		return tx.SaveGist(&gist.Gist{ID: "abc123", UserID: 1000, Description: "My Gist"})
+		return tx.SaveGist(&gist.Gist{ID: "abc123", UserID: 1000, Public: true, Description: "My Gist"})
 	})
 
 	// Retrieve oEmbed.
@@ -205,6 +300,75 @@ func TestHandler_OEmbed_WidthHeight(t *testing.T) {
 	equals(t, 60, o.Height)
 }
 
+// Ensure an oEmbed is constrained to the consumer's maximum size.
+func TestHandler_OEmbed_MaxWidthHeight(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "abc123", UserID: 1000, Public: true})
+	})
+
+	var tests = []struct {
+		url                 string
+		maxwidth, maxheight string
+		width, height       int
+		status              int
+	}{
+		{url: "https://gist.exposed/benbjohnson/abc123", maxwidth: "400", width: 400, height: 300, status: 200},
+		{url: "https://gist.exposed/benbjohnson/abc123", maxheight: "200", width: 600, height: 200, status: 200},
+		{url: "https://gist.exposed/benbjohnson/abc123?width=300&height=100", maxwidth: "400", maxheight: "200", width: 300, height: 100, status: 200},
+		{url: "https://gist.exposed/benbjohnson/abc123?width=500&height=500", maxwidth: "400", maxheight: "200", width: 400, height: 200, status: 200},
+		{url: "https://gist.exposed/benbjohnson/abc123", maxwidth: "wide", status: 400},
+		{url: "https://gist.exposed/benbjohnson/abc123", maxheight: "-1", status: 400},
+	}
+	for i, tt := range tests {
+		v := url.Values{"url": {tt.url}}
+		if tt.maxwidth != "" {
+			v.Set("maxwidth", tt.maxwidth)
+		}
+		if tt.maxheight != "" {
+			v.Set("maxheight", tt.maxheight)
+		}
+		resp, err := http.Get(h.Server.URL + "/oembed.json?" + v.Encode())
+		ok(t, err)
+
+		var o struct {
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+			HTML   string `json:"html"`
+		}
+		json.NewDecoder(resp.Body).Decode(&o)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%d. status: exp: %d, got: %d", i, tt.status, resp.StatusCode)
+		} else if tt.width != o.Width || tt.height != o.Height {
+			t.Errorf("%d. size: exp: %dx%d, got: %dx%d", i, tt.width, tt.height, o.Width, o.Height)
+		} else if tt.width > 0 && !strings.Contains(o.HTML, "max-width: "+strconv.Itoa(tt.width)+"px;") {
+			t.Errorf("%d. expected max-width in html: %s", i, o.HTML)
+		} else if tt.height > 0 && !strings.Contains(o.HTML, "padding-bottom: "+strconv.Itoa(tt.height)+"px;") {
+			t.Errorf("%d. expected padding-bottom in html: %s", i, o.HTML)
+		}
+	}
+}
+
+// Ensure an oEmbed for a private gist returns a 401.
+func TestHandler_OEmbed_ErrUnauthorized(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "abc123", UserID: 1000, Public: false})
+	})
+
+	u, _ := url.Parse(h.Server.URL + "/oembed.json")
+	u.RawQuery = (&url.Values{"url": {"https://gist.exposed/benbjohnson/abc123"}}).Encode()
+	resp, err := http.Get(u.String())
+	ok(t, err)
+	resp.Body.Close()
+	equals(t, 401, resp.StatusCode)
+}
+
 // Ensure an oEmbed for a missing gist returns a 404.
 func TestHandler_OEmbed_ErrNotFound(t *testing.T) {
 	h := NewTestHandler()
@@ -231,13 +395,56 @@ func TestHandler_OEmbed_ErrInvalidPath(t *testing.T) {
 	equals(t, 404, resp.StatusCode)
 }
 
-// Ensure an XML oEmbed returns an error.
-func TestHandler_OEmbed_XML_ErrStatusNotImplemented(t *testing.T) {
+// Ensure an XML oEmbed is processed correctly.
+func TestHandler_OEmbed_XML(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+
+	// Create the gist in the database.
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "abc123", UserID: 1000, Public: true, Description: "My <Gist>"})
+	})
+
+	// Retrieve oEmbed from the XML endpoint and with the format parameter.
+	for _, path := range []string{"/oembed.xml", "/oembed?format=xml"} {
+		u, _ := url.Parse(h.Server.URL + path)
+		q := u.Query()
+		q.Set("url", "https://gist.exposed/benbjohnson/abc123")
+		u.RawQuery = q.Encode()
+		resp, err := http.Get(u.String())
+		ok(t, err)
+		equals(t, 200, resp.StatusCode)
+		equals(t, "text/xml; charset=utf-8", resp.Header.Get("Content-Type"))
+		equals(t, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+			`<oembed><version>1.0</version><type>rich</type>`+
+			`<html>&lt;div class=&#34;gist-exposed&#34; style=&#34;position: relative; padding-bottom: 300px; padding-top: 0px; height: 0; overflow: hidden; max-width: 600px;&#34;&gt;&lt;iframe style=&#34;position: absolute; top:0; left: 0; width: 100%; height: 100%; border: none;&#34; src=&#34;https://gist.exposed/benbjohnson/abc123/&#34;&gt;&lt;/iframe&gt;&lt;/div&gt;</html>`+
+			`<width>600</width><height>300</height><title>My &lt;Gist&gt;</title>`+
+			`<provider_name>Gist Exposed!</provider_name><provider_url>https://gist.exposed</provider_url></oembed>`, readall(resp.Body))
+		resp.Body.Close()
+	}
+}
+
+// Ensure an XML oEmbed for a missing gist returns a 404.
+func TestHandler_OEmbed_XML_ErrNotFound(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+
+	u, _ := url.Parse(h.Server.URL + "/oembed.xml")
+	u.RawQuery = (&url.Values{"url": {"https://gist.exposed/benbjohnson/abc123"}}).Encode()
+	resp, err := http.Get(u.String())
+	ok(t, err)
+	resp.Body.Close()
+	equals(t, 404, resp.StatusCode)
+}
+
+// Ensure an oEmbed with an unsupported format returns an error.
+func TestHandler_OEmbed_ErrStatusNotImplemented(t *testing.T) {
 	h := NewTestHandler()
 	defer h.Close()
 
 	// Retrieve oEmbed.
-	resp, _ := http.Get(h.Server.URL + "/oembed.xml")
+	resp, err := http.Get(h.Server.URL + "/oembed?format=yaml")
+	ok(t, err)
 	resp.Body.Close()
 	equals(t, 501, resp.StatusCode)
 }
@@ -272,6 +479,7 @@ func TestHandler_Gist_Authorized(t *testing.T) {
 			Description: "my gist",
 			Public:      true,
 			URL:         "-",
+			Revision:    "aaa",
 			Files: []*gist.GistFile{
 				&gist.GistFile{Size: 100, Filename: "index.html", RawURL: s.URL + "/index.html"},
 				&gist.GistFile{Size: 200, Filename: "awesome.js", RawURL: s.URL + "/awesome.js"},
@@ -295,26 +503,692 @@ func TestHandler_Gist_Authorized(t *testing.T) {
 	equals(t, 200, resp.StatusCode)
 	equals(t, `<html><body></body></html>`, string(body))
 
-	// The files should be saved to the hard drive.
+	// The files should be saved to the blob store.
 	var content []byte
-	content, _ = ioutil.ReadFile(filepath.Join(h.DB.GistPath, "xxx", "index.html"))
+	path, _ := h.DB.GistFilePath("xxx", "", "index.html")
+	content, _ = ioutil.ReadFile(path)
 	equals(t, `<html><body></body></html>`, string(content))
-	content, _ = ioutil.ReadFile(filepath.Join(h.DB.GistPath, "xxx", "awesome.js"))
+	path, _ = h.DB.GistFilePath("xxx", "", "awesome.js")
+	content, _ = ioutil.ReadFile(path)
 	equals(t, `alert(100);`, string(content))
 
+	// The files should also be available under the revision.
+	path, _ = h.DB.GistFilePath("xxx", "aaa", "index.html")
+	content, _ = ioutil.ReadFile(path)
+	equals(t, `<html><body></body></html>`, string(content))
+
 	// The gist should be saved to the db.
 	h.DB.View(func(tx *gist.Tx) error {
 		g, _ := tx.Gist("xxx")
 		assert(t, g != nil, "expected gist")
+		g, _ = tx.GistRevision("xxx", "aaa")
+		assert(t, g != nil, "expected gist revision")
 		return nil
 	})
 }
 
-// Ensure a path is correctly parsed into gist id and filename.
+// Ensure reloads are throttled per gist and per user and that throttled
+// requests are served from the disk cache.
+func TestHandler_Gist_ReloadThrottled(t *testing.T) {
+	// Run mock GitHub raw server.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><body></body></html>`))
+	}))
+	defer s.Close()
+
+	// Create the mock session store.
+	store := NewTestStore()
+	session := sessions.NewSession(store, "")
+	session.Values["UserID"] = 1000
+	store.GetFunc = func(r *http.Request, name string) (*sessions.Session, error) { return session, nil }
+
+	// Return gist data and count the calls.
+	var n int
+	client := &MockGitHubClient{}
+	client.GistFunc = func(id string) (*gist.Gist, error) {
+		n++
+		return &gist.Gist{ID: id, UserID: 1000, Files: []*gist.GistFile{
+			{Filename: "index.html", RawURL: s.URL + "/" + id + "/index.html"},
+		}}, nil
+	}
+
+	// Setup handler to only allow two reloads per user.
+	h := NewTestHandler()
+	h.Handler.Store = store
+	h.Handler.NewGitHubClient = func(token string) gist.GitHubClient { return client }
+	h.DB.NewGitHubClient = h.Handler.NewGitHubClient
+	h.Handler.ReloadInterval = time.Hour
+	h.Handler.UserReloadInterval = time.Hour
+	h.Handler.UserReloadBurst = 2
+	defer h.Close()
+
+	// The first request should reload and the second should use the cache.
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(h.Server.URL + "/xxx/")
+		ok(t, err)
+		equals(t, 200, resp.StatusCode)
+		equals(t, `<html><body></body></html>`, readall(resp.Body))
+		resp.Body.Close()
+	}
+	equals(t, 1, n)
+
+	// The user's second reload is allowed but the third is not.
+	resp, _ := http.Get(h.Server.URL + "/yyy/")
+	resp.Body.Close()
+	equals(t, 200, resp.StatusCode)
+	resp, _ = http.Get(h.Server.URL + "/zzz/")
+	resp.Body.Close()
+	equals(t, 404, resp.StatusCode)
+	equals(t, 2, n)
+}
+
+// Ensure the cached copy of a gist is served when GitHub is throttling requests.
+func TestHandler_Gist_RateLimited(t *testing.T) {
+	// Create the mock session store.
+	store := NewTestStore()
+	session := sessions.NewSession(store, "")
+	session.Values["UserID"] = 1000
+	store.GetFunc = func(r *http.Request, name string) (*sessions.Session, error) { return session, nil }
+
+	// Return a rate limit error from GitHub.
+	client := &MockGitHubClient{}
+	client.GistFunc = func(id string) (*gist.Gist, error) {
+		return nil, &gist.RateLimitError{Reset: time.Now().Add(time.Hour), Message: "API rate limit exceeded"}
+	}
+
+	// Setup handler.
+	h := NewTestHandler()
+	h.Handler.Store = store
+	h.Handler.NewGitHubClient = func(token string) gist.GitHubClient { return client }
+	h.DB.NewGitHubClient = h.Handler.NewGitHubClient
+	defer h.Close()
+
+	// Save a cached copy of the gist.
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "xxx", Files: []*gist.GistFile{
+			{Filename: "index.html", Hash: MustWriteBlob(h.DB, `cached`)},
+		}})
+	})
+
+	resp, err := http.Get(h.Server.URL + "/xxx/")
+	ok(t, err)
+	defer resp.Body.Close()
+	equals(t, 200, resp.StatusCode)
+	equals(t, `110 - "GitHub is throttling us, serving cached copy"`, resp.Header.Get("Warning"))
+	equals(t, `cached`, readall(resp.Body))
+
+	// Gists without a cached copy are unavailable until the limit resets.
+	resp, err = http.Get(h.Server.URL + "/yyy/")
+	ok(t, err)
+	defer resp.Body.Close()
+	equals(t, 503, resp.StatusCode)
+	equals(t, "3600", resp.Header.Get("Retry-After"))
+	equals(t, "GitHub is throttling us, try again later\n", readall(resp.Body))
+}
+
+// Ensure a pinned revision is served from the revision cache.
+func TestHandler_Gist_Revision(t *testing.T) {
+	const rev = "57a7f021a713b1c5a6a199b54cc514735d2d462f"
+
+	h := NewTestHandler()
+	defer h.Close()
+
+	// Save a revision and a newer revision of the gist.
+	h.DB.Update(func(tx *gist.Tx) error {
+		tx.SaveGist(&gist.Gist{ID: "xxx", Revision: rev, Files: []*gist.GistFile{
+			{Filename: "index.html", Hash: MustWriteBlob(h.DB, `old`)},
+		}})
+		return tx.SaveGist(&gist.Gist{ID: "xxx", Revision: "bbb", Files: []*gist.GistFile{
+			{Filename: "index.html", Hash: MustWriteBlob(h.DB, `new`)},
+		}})
+	})
+
+	// The pinned revision should be returned with immutable caching.
+	resp, err := http.Get(h.Server.URL + "/xxx/" + rev + "/")
+	ok(t, err)
+	equals(t, 200, resp.StatusCode)
+	equals(t, gist.ImmutableCacheControl, resp.Header.Get("Cache-Control"))
+	equals(t, `old`, readall(resp.Body))
+	resp.Body.Close()
+
+	// The unpinned URL should track the latest.
+	resp, err = http.Get(h.Server.URL + "/xxx/index.html")
+	ok(t, err)
+	equals(t, "public, max-age=60, stale-while-revalidate=3600", resp.Header.Get("Cache-Control"))
+	equals(t, `new`, readall(resp.Body))
+	resp.Body.Close()
+}
+
+// Ensure that the cache policy can be configured per deployment and per gist.
+func TestHandler_Gist_CachePolicy(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+	h.CachePolicy = gist.CachePolicy{MaxAge: 300}
+
+	h.DB.Update(func(tx *gist.Tx) error {
+		tx.SaveGist(&gist.Gist{ID: "xxx", Files: []*gist.GistFile{
+			{Filename: "index.html", Hash: MustWriteBlob(h.DB, `xxx`)},
+		}})
+		return tx.SaveGist(&gist.Gist{ID: "yyy", Config: &gist.GistConfig{Cache: &gist.CachePolicy{MaxAge: 10, StaleWhileRevalidate: 20, Immutable: true}}, Files: []*gist.GistFile{
+			{Filename: "index.html", Hash: MustWriteBlob(h.DB, `yyy`)},
+		}})
+	})
+
+	resp, err := http.Get(h.Server.URL + "/xxx/index.html")
+	ok(t, err)
+	equals(t, "public, max-age=300", resp.Header.Get("Cache-Control"))
+	resp.Body.Close()
+
+	resp, err = http.Get(h.Server.URL + "/yyy/index.html")
+	ok(t, err)
+	equals(t, "public, max-age=10, stale-while-revalidate=20, immutable", resp.Header.Get("Cache-Control"))
+	resp.Body.Close()
+}
+
+// Ensure that cached gist files vary by session cookie unless they are served
+// from a separate content origin, which never receives the cookie.
+func TestHandler_Gist_VaryCookie(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "xxx", Files: []*gist.GistFile{
+			{Filename: "index.html", Hash: MustWriteBlob(h.DB, `xxx`)},
+		}})
+	})
+
+	resp, err := http.Get(h.Server.URL + "/xxx/index.html")
+	ok(t, err)
+	resp.Body.Close()
+	equals(t, []string{"Accept-Encoding", "Cookie"}, resp.Header["Vary"])
+
+	// Responses from the content origin do not vary by cookie.
+	h.AppOrigin = "https://gist.exposed"
+	h.ContentOrigin = h.Server.URL
+	resp, err = http.Get(h.Server.URL + "/xxx/index.html")
+	ok(t, err)
+	resp.Body.Close()
+	equals(t, []string{"Accept-Encoding"}, resp.Header["Vary"])
+}
+
+// Ensure that the oEmbed cache age is configurable.
+func TestHandler_OEmbed_CacheAge(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+	h.EmbedCacheAge = 60
+
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "abc123", UserID: 1000, Public: true})
+	})
+
+	u, _ := url.Parse(h.Server.URL + "/oembed.json")
+	u.RawQuery = (&url.Values{"url": {"https://gist.exposed/benbjohnson/abc123"}}).Encode()
+	resp, err := http.Get(u.String())
+	ok(t, err)
+	defer resp.Body.Close()
+
+	var o struct {
+		CacheAge int `json:"cache_age"`
+	}
+	ok(t, json.NewDecoder(resp.Body).Decode(&o))
+	equals(t, 60, o.CacheAge)
+}
+
+// Ensure that security headers are set on gist files and application pages.
+func TestHandler_SecurityHeaders(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "xxx", Files: []*gist.GistFile{
+			{Filename: "index.html", Hash: MustWriteBlob(h.DB, `<script></script>`)},
+		}})
+	})
+
+	// Gist files are sandboxed but can be framed by any site.
+	resp, err := http.Get(h.Server.URL + "/xxx/index.html")
+	ok(t, err)
+	resp.Body.Close()
+	equals(t, 200, resp.StatusCode)
+	equals(t, gist.DefaultContentSecurityPolicy, resp.Header.Get("Content-Security-Policy"))
+	equals(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+
+	// Application pages cannot be framed.
+	resp, err = http.Get(h.Server.URL + "/")
+	ok(t, err)
+	resp.Body.Close()
+	equals(t, gist.AppContentSecurityPolicy, resp.Header.Get("Content-Security-Policy"))
+	equals(t, "nosniff", resp.Header.Get("X-Content-Type-Options"))
+}
+
+// Ensure that gist files are only served from a separate content origin.
+func TestHandler_ContentOrigin(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+	h.ContentOrigin = "https://content.example.com"
+
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "xxx", UserID: 1000, Public: true, Files: []*gist.GistFile{
+			{Filename: "index.html", Hash: MustWriteBlob(h.DB, `hello`)},
+		}})
+	})
+
+	// Create non-redirecting client.
+	var redirectURL *url.URL
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			redirectURL = req.URL
+			return errors.New("no redirects")
+		},
+	}
+
+	// Gist requests on the app origin are redirected to the content origin.
+	resp, _ := client.Get(h.Server.URL + "/xxx/index.html?a=1")
+	resp.Body.Close()
+	equals(t, "https://content.example.com/xxx/index.html?a=1", redirectURL.String())
+
+	// Gist files are served on the content origin.
+	get := func(path string) *http.Response {
+		req, _ := http.NewRequest("GET", h.Server.URL+path, nil)
+		req.Host = "content.example.com"
+		resp, err := client.Do(req)
+		ok(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	resp = get("/xxx/index.html")
+	equals(t, 200, resp.StatusCode)
+
+	// Pages keep their origin so they can fetch the other files in the gist.
+	equals(t, gist.ContentOriginSecurityPolicy, resp.Header.Get("Content-Security-Policy"))
+
+	// Application routes are not available on the content origin.
+	for _, path := range []string{"/", "/_/dashboard", "/_/login", "/_/login/callback", "/_/logout", "/oembed.json"} {
+		if resp := get(path); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: unexpected status: %d", path, resp.StatusCode)
+		}
+	}
+
+	// oEmbed frames point directly at the content origin.
+	u, _ := url.Parse(h.Server.URL + "/oembed.json")
+	u.RawQuery = (&url.Values{"url": {"https://gist.exposed/benbjohnson/xxx"}}).Encode()
+	resp, err := http.Get(u.String())
+	ok(t, err)
+	body := readall(resp.Body)
+	resp.Body.Close()
+	assert(t, strings.Contains(body, `src=\"https://content.example.com/benbjohnson/xxx/\"`), "unexpected body: %s", body)
+}
+
+// Ensure that oEmbed discovery links are added to HTML gist files.
+func TestHandler_Gist_OEmbedDiscovery(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+	h.OEmbedDiscovery = true
+	h.AppOrigin = "https://gist.example.com"
+
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "xxx", Files: []*gist.GistFile{
+			{Filename: "index.html", Hash: MustWriteBlob(h.DB, `<html><HEAD><title>x</title></HEAD><body></body></html>`)},
+			{Filename: "bare.html", Hash: MustWriteBlob(h.DB, `<p>hi</p>`)},
+			{Filename: "app.js", Hash: MustWriteBlob(h.DB, `</head>`)},
+			{Filename: "doctype.html", Hash: MustWriteBlob(h.DB, `<!DOCTYPE html><p>hi</p>`)},
+			{Filename: "open.html", Hash: MustWriteBlob(h.DB, `<!doctype html><header></header><Head lang="en"><title>x</title>`)},
+			{Filename: "unicode.html", Hash: MustWriteBlob(h.DB, `<html><head><title>İİİİ</title></head></html>`)},
+		}})
+	})
+
+	const links = `<link rel="alternate" type="application/json+oembed" href="https://gist.example.com/oembed.json?url=https%3A%2F%2Fgist.example.com%2Fxxx%2Findex.html">` +
+		`<link rel="alternate" type="text/xml+oembed" href="https://gist.example.com/oembed.xml?url=https%3A%2F%2Fgist.example.com%2Fxxx%2Findex.html">`
+
+	// Links are inserted at the end of the head.
+	resp, err := http.Get(h.Server.URL + "/xxx/index.html")
+	ok(t, err)
+	equals(t, `<html><HEAD><title>x</title>`+links+`</HEAD><body></body></html>`, readall(resp.Body))
+	resp.Body.Close()
+
+	// Links are inserted at the start of documents without a head.
+	resp, err = http.Get(h.Server.URL + "/xxx/bare.html")
+	ok(t, err)
+	body := readall(resp.Body)
+	resp.Body.Close()
+	assert(t, strings.HasPrefix(body, `<link rel="alternate" type="application/json+oembed"`), "unexpected body: %s", body)
+	assert(t, strings.HasSuffix(body, `<p>hi</p>`), "unexpected body: %s", body)
+
+	// Other files are not changed.
+	resp, err = http.Get(h.Server.URL + "/xxx/app.js")
+	ok(t, err)
+	equals(t, `</head>`, readall(resp.Body))
+	resp.Body.Close()
+
+	// Links are never inserted before the doctype or inside other markup.
+	for _, tt := range []struct {
+		filename string
+		prefix   string
+		suffix   string
+	}{
+		{filename: "doctype.html", prefix: `<!DOCTYPE html><link `, suffix: `><p>hi</p>`},
+		{filename: "open.html", prefix: `<!doctype html><header></header><Head lang="en"><link `, suffix: `><title>x</title>`},
+		{filename: "unicode.html", prefix: `<html><head><title>İİİİ</title><link `, suffix: `></head></html>`},
+	} {
+		resp, err = http.Get(h.Server.URL + "/xxx/" + tt.filename)
+		ok(t, err)
+		body := readall(resp.Body)
+		resp.Body.Close()
+		assert(t, strings.HasPrefix(body, tt.prefix), "%s: unexpected body: %s", tt.filename, body)
+		assert(t, strings.HasSuffix(body, tt.suffix), "%s: unexpected body: %s", tt.filename, body)
+	}
+}
+
+// Ensure that HTML gist files and oEmbeds include the resize scripts.
+func TestHandler_EmbedResize(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+	h.EmbedResize = true
+	h.AppOrigin = "https://gist.example.com"
+
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "abc123", Public: true, Files: []*gist.GistFile{
+			{Filename: "index.html", Hash: MustWriteBlob(h.DB, `<html><head></head></html>`)},
+			{Filename: "app.js", Hash: MustWriteBlob(h.DB, `</head>`)},
+		}})
+	})
+
+	// The page script is added to HTML files only.
+	resp, err := http.Get(h.Server.URL + "/abc123/index.html")
+	ok(t, err)
+	equals(t, `<html><head><script async src="/_/resize.js"></script></head></html>`, readall(resp.Body))
+	resp.Body.Close()
+
+	resp, err = http.Get(h.Server.URL + "/abc123/app.js")
+	ok(t, err)
+	equals(t, `</head>`, readall(resp.Body))
+	resp.Body.Close()
+
+	// The oEmbed HTML includes the listener and the maximum height.
+	v := url.Values{"url": {"https://gist.exposed/benbjohnson/abc123"}, "maxheight": {"500"}}
+	resp, err = http.Get(h.Server.URL + "/oembed.json?" + v.Encode())
+	ok(t, err)
+	var o struct {
+		HTML string `json:"html"`
+	}
+	ok(t, json.NewDecoder(resp.Body).Decode(&o))
+	resp.Body.Close()
+	assert(t, strings.Contains(o.HTML, ` data-max-height="500">`), "unexpected html: %s", o.HTML)
+	assert(t, strings.HasSuffix(o.HTML, `</div><script async src="https://gist.example.com/_/embed.js"></script>`), "unexpected html: %s", o.HTML)
+
+	// Both scripts are served.
+	for _, path := range []string{"/_/resize.js", "/_/embed.js"} {
+		resp, err = http.Get(h.Server.URL + path)
+		ok(t, err)
+		body := readall(resp.Body)
+		resp.Body.Close()
+		equals(t, 200, resp.StatusCode)
+		equals(t, "application/javascript; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert(t, strings.Contains(body, "gist-exposed:resize"), "unexpected script: %s", body)
+	}
+}
+
+// Ensure that a gist can be embedded with a script tag.
+func TestHandler_GistScript(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+	h.AppOrigin = "https://gist.example.com"
+
+	h.DB.Update(func(tx *gist.Tx) error {
+		tx.SaveGist(&gist.Gist{ID: "abc123", Public: true, Files: []*gist.GistFile{
+			{Filename: "main.go", Hash: MustWriteBlob(h.DB, "package main\n\nfunc main() {}\n")},
+			{Filename: "index.html", Hash: MustWriteBlob(h.DB, `</script><b>`)},
+			{Filename: ".gistconfig", Hash: MustWriteBlob(h.DB, `{}`)},
+		}})
+		return tx.SaveGist(&gist.Gist{ID: "def456", Public: false})
+	})
+
+	// All files are written along with the stylesheet.
+	resp, err := http.Get(h.Server.URL + "/benbjohnson/abc123.js")
+	ok(t, err)
+	equals(t, 200, resp.StatusCode)
+	equals(t, "application/javascript; charset=utf-8", resp.Header.Get("Content-Type"))
+	body := readall(resp.Body)
+	resp.Body.Close()
+	assert(t, !strings.Contains(body, "</script>"), "unescaped script: %s", body)
+
+	a := MustParseDocumentWrites(body)
+	equals(t, 2, len(a))
+	equals(t, `<link rel="stylesheet" href="https://gist.example.com/_/code.css">`, a[0])
+	assert(t, strings.Contains(a[1], `<tr id="file-main-go-L3"><td class="gist-exposed-num"><a href="#file-main-go-L3" data-line-number="3"></a></td>`), "unexpected html: %s", a[1])
+	assert(t, strings.Contains(a[1], `<span class="gist-exposed-kd">func</span>`), "unexpected html: %s", a[1])
+	assert(t, strings.Contains(a[1], `<span class="gist-exposed-nt">script</span>`), "unexpected html: %s", a[1])
+	assert(t, !strings.Contains(a[1], `<b>`), "unexpected html: %s", a[1])
+	assert(t, strings.Contains(a[1], `<a href="https://gist.example.com/abc123/main.go">main.go</a>`), "unexpected html: %s", a[1])
+	assert(t, !strings.Contains(a[1], `.gistconfig`), "unexpected html: %s", a[1])
+
+	// A single file can be selected.
+	resp, err = http.Get(h.Server.URL + "/abc123.js?file=index.html")
+	ok(t, err)
+	a = MustParseDocumentWrites(readall(resp.Body))
+	resp.Body.Close()
+	assert(t, !strings.Contains(a[1], `main.go`), "unexpected html: %s", a[1])
+
+	// Missing files and gists are not found and private gists are refused.
+	for _, tt := range []struct {
+		path   string
+		status int
+	}{
+		{"/abc123.js?file=missing.go", 404},
+		{"/bad000.js", 404},
+		{"/def456.js", 401},
+	} {
+		resp, err = http.Get(h.Server.URL + tt.path)
+		ok(t, err)
+		resp.Body.Close()
+		equals(t, tt.status, resp.StatusCode)
+	}
+
+	// The stylesheet is served.
+	resp, err = http.Get(h.Server.URL + "/_/code.css")
+	ok(t, err)
+	resp.Body.Close()
+	equals(t, 200, resp.StatusCode)
+	equals(t, "text/css; charset=utf-8", resp.Header.Get("Content-Type"))
+}
+
+// Ensure that gist files can be served as highlighted HTML.
+func TestHandler_Gist_CodeView(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "abc123", Public: true, Files: []*gist.GistFile{
+			{Filename: "main.go", Hash: MustWriteBlob(h.DB, "package main\n\nfunc main() {}\n")},
+			{Filename: "index.html", Hash: MustWriteBlob(h.DB, `<script>alert(1)</script>`)},
+			{Filename: "data.bin", Hash: MustWriteBlob(h.DB, "\x00\x01\x02")},
+		}})
+	})
+
+	// The view can be selected with a parameter or a path prefix.
+	for _, path := range []string{"/abc123/main.go?view=code", "/_/view/abc123/main.go"} {
+		resp, err := http.Get(h.Server.URL + path)
+		ok(t, err)
+		body := readall(resp.Body)
+		resp.Body.Close()
+		equals(t, 200, resp.StatusCode)
+		equals(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert(t, strings.HasPrefix(body, `<!DOCTYPE html>`), "unexpected body: %s", body)
+		assert(t, strings.Contains(body, `.gist-exposed-chroma .gist-exposed-kd {`), "missing highlighting styles: %s", body)
+		assert(t, strings.Contains(body, `<tr id="L3"><td class="gist-exposed-num"><a href="#L3" data-line-number="3"></a></td><td class="gist-exposed-line"><span class="gist-exposed-kd">func</span>`), "unexpected body: %s", body)
+	}
+
+	// HTML is listed rather than rendered.
+	resp, err := http.Get(h.Server.URL + "/_/view/abc123/")
+	ok(t, err)
+	body := readall(resp.Body)
+	resp.Body.Close()
+	assert(t, !strings.Contains(body, `<script>`), "unescaped html: %s", body)
+
+	// Binary files are not listed.
+	resp, err = http.Get(h.Server.URL + "/abc123/data.bin?view=code")
+	ok(t, err)
+	body = readall(resp.Body)
+	resp.Body.Close()
+	assert(t, strings.Contains(body, `Binary file not shown.`), "unexpected body: %s", body)
+
+	// The code view can be embedded with oEmbed.
+	v := url.Values{"url": {"https://gist.exposed/_/view/abc123/main.go"}}
+	resp, err = http.Get(h.Server.URL + "/oembed.json?" + v.Encode())
+	ok(t, err)
+	var o struct {
+		HTML string `json:"html"`
+	}
+	ok(t, json.NewDecoder(resp.Body).Decode(&o))
+	resp.Body.Close()
+	equals(t, 200, resp.StatusCode)
+	assert(t, strings.Contains(o.HTML, `src="https://gist.exposed/_/view/abc123/main.go"`), "unexpected html: %s", o.HTML)
+}
+
+// Ensure that nested files are served even if a directory name looks like a gist ID.
+func TestHandler_Gist_NestedPath(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+	h.DB.DirSeparator = "__"
+
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "abc123", Files: []*gist.GistFile{
+			{Filename: "d3__d3.min.js", Hash: MustWriteBlob(h.DB, `d3`)},
+			{Filename: "2020__index.html", Hash: MustWriteBlob(h.DB, `2020`)},
+			{Filename: "cafe__x.css", Hash: MustWriteBlob(h.DB, `cafe`)},
+		}})
+	})
+
+	for _, tt := range []struct {
+		path string
+		body string
+	}{
+		{"/abc123/d3/d3.min.js", "d3"},
+		{"/abc123/2020/", "2020"},
+		{"/abc123/cafe/x.css", "cafe"},
+		{"/benbjohnson/abc123/d3/d3.min.js", "d3"},
+		{"/cafe/abc123/cafe/x.css", "cafe"},
+	} {
+		resp, err := http.Get(h.Server.URL + tt.path)
+		ok(t, err)
+		equals(t, 200, resp.StatusCode)
+		equals(t, tt.body, readall(resp.Body))
+		resp.Body.Close()
+	}
+}
+
+// Ensure that gist files support conditional, range and HEAD requests.
+func TestHandler_Gist_ServeContent(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+
+	hash := MustWriteBlob(h.DB, `hello, world`)
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "xxx", SyncedAt: parsetime("2000-01-01T00:00:00Z"), Files: []*gist.GistFile{
+			{Filename: "hello.txt", Hash: hash},
+		}})
+	})
+	u := h.Server.URL + "/xxx/hello.txt"
+
+	// A full response should include validators and the content length.
+	resp, err := http.Get(u)
+	ok(t, err)
+	equals(t, 200, resp.StatusCode)
+	equals(t, `"`+hash+`"`, resp.Header.Get("ETag"))
+	equals(t, "Sat, 01 Jan 2000 00:00:00 GMT", resp.Header.Get("Last-Modified"))
+	equals(t, "12", resp.Header.Get("Content-Length"))
+	equals(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+	equals(t, `hello, world`, readall(resp.Body))
+	resp.Body.Close()
+
+	// Matching validators should return not modified.
+	for _, hdr := range [][2]string{{"If-None-Match", `"` + hash + `"`}, {"If-Modified-Since", "Sat, 01 Jan 2000 00:00:00 GMT"}} {
+		req, _ := http.NewRequest("GET", u, nil)
+		req.Header.Set(hdr[0], hdr[1])
+		resp, err = http.DefaultClient.Do(req)
+		ok(t, err)
+		equals(t, 304, resp.StatusCode)
+		resp.Body.Close()
+	}
+
+	// A range request should return partial content.
+	req, _ := http.NewRequest("GET", u, nil)
+	req.Header.Set("Range", "bytes=7-11")
+	resp, err = http.DefaultClient.Do(req)
+	ok(t, err)
+	equals(t, 206, resp.StatusCode)
+	equals(t, "bytes 7-11/12", resp.Header.Get("Content-Range"))
+	equals(t, `world`, readall(resp.Body))
+	resp.Body.Close()
+
+	// A HEAD request should return headers without a body.
+	resp, err = http.Head(u)
+	ok(t, err)
+	equals(t, 200, resp.StatusCode)
+	equals(t, "12", resp.Header.Get("Content-Length"))
+	equals(t, ``, readall(resp.Body))
+	resp.Body.Close()
+}
+
+// Ensure that files with an unknown extension are not sniffed as HTML.
+func TestHandler_Gist_UnknownContentType(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+
+	hash := MustWriteBlob(h.DB, `<html><script>alert(1)</script></html>`)
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "xxx", Files: []*gist.GistFile{
+			{Filename: "page.unknownext", Hash: hash},
+			{Filename: "README", Hash: hash},
+		}})
+	})
+
+	for _, filename := range []string{"page.unknownext", "README"} {
+		resp, err := http.Get(h.Server.URL + "/xxx/" + filename)
+		ok(t, err)
+		equals(t, 200, resp.StatusCode)
+		equals(t, "application/octet-stream", resp.Header.Get("Content-Type"))
+		resp.Body.Close()
+	}
+}
+
+// Ensure that hostile paths cannot read files outside of the blob store.
+func TestHandler_Gist_ErrPathTraversal(t *testing.T) {
+	h := NewTestHandler()
+	defer h.Close()
+
+	h.DB.Update(func(tx *gist.Tx) error {
+		return tx.SaveGist(&gist.Gist{ID: "xxx", Files: []*gist.GistFile{
+			{Filename: "index.html", Hash: MustWriteBlob(h.DB, `ok`)},
+		}})
+	})
+
+	for i, path := range []string{
+		"/xxx/%2e%2e/%2e%2e/db",
+		"/xxx/%2e%2e%2fdb",
+		"/%2e%2e/xxx/index.html",
+		"/user/%2e%2e/db",
+		"/xxx/..%5c..%5cdb",
+		"/xxx/%2fetc%2fpasswd",
+		"/xxx/css/%2e/index.html",
+		"/xxx/index.html%00.txt",
+	} {
+		resp, err := http.Get(h.Server.URL + path)
+		ok(t, err)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%d. %s: unexpected status: %d", i, path, resp.StatusCode)
+		}
+		resp.Body.Close()
+	}
+}
+
+// Ensure a path is correctly parsed into gist id, revision and filename.
 func TestParsePath(t *testing.T) {
+	const rev = "57a7f021a713b1c5a6a199b54cc514735d2d462f"
 	var tests = []struct {
 		path     string
 		gistID   string
+		revision string
 		filename string
 		err      string
 	}{
@@ -325,10 +1199,40 @@ func TestParsePath(t *testing.T) {
 		{path: "/user100/abc123", gistID: "abc123", filename: "", err: "non-canonical path"},
 		{path: "/user100/abc123/", gistID: "abc123", filename: "", err: ""},
 		{path: "/user100/abc123/index.html", gistID: "abc123", filename: "index.html", err: ""},
-		{path: "/user100/abc123/subdir/index.html", gistID: "", filename: "", err: "invalid path: /user100/abc123/subdir/index.html"},
+		{path: "/user100/abc123/subdir/index.html", gistID: "abc123", filename: "subdir/index.html", err: ""},
+		{path: "/abc123/css/style.css", gistID: "abc123", filename: "css/style.css", err: ""},
+		{path: "/abc123/css/", gistID: "abc123", filename: "css/", err: ""},
+		{path: "/abc123/css/../../etc/passwd", gistID: "", filename: "", err: "invalid path: /abc123/css/../../etc/passwd"},
+		{path: "/abc123/css//style.css", gistID: "", filename: "", err: "invalid path: /abc123/css//style.css"},
+		{path: "/abc123/./style.css", gistID: "", filename: "", err: "invalid path: /abc123/./style.css"},
+		{path: "/../index.html", gistID: "", filename: "", err: "invalid path: /../index.html"},
+		{path: "//abc123/index.html", gistID: "", filename: "", err: "invalid path: //abc123/index.html"},
+		{path: "/user100/../index.html", gistID: "", filename: "", err: "invalid path: /user100/../index.html"},
+		{path: "/abc123/..\\db", gistID: "", filename: "", err: "invalid path: /abc123/..\\db"},
+		{path: "/abc123/a\x00.html", gistID: "", filename: "", err: "invalid path: /abc123/a\x00.html"},
+		{path: "/abc.123/", gistID: "", filename: "", err: "invalid path: /abc.123/"},
+		{path: "/abc123/" + rev, gistID: "abc123", revision: rev, filename: "", err: "non-canonical path"},
+		{path: "/abc123/" + rev + "/", gistID: "abc123", revision: rev, filename: "", err: ""},
+		{path: "/abc123/" + rev + "/index.html", gistID: "abc123", revision: rev, filename: "index.html", err: ""},
+		{path: "/user100/abc123/" + rev, gistID: "abc123", revision: rev, filename: "", err: "non-canonical path"},
+		{path: "/user100/abc123/" + rev + "/", gistID: "abc123", revision: rev, filename: "", err: ""},
+		{path: "/user100/abc123/" + rev + "/index.html", gistID: "abc123", revision: rev, filename: "index.html", err: ""},
+		{path: "/abc123/" + rev + "/css/style.css", gistID: "abc123", revision: rev, filename: "css/style.css", err: ""},
+		{path: "/user100/abc123/" + rev + "/css/style.css", gistID: "abc123", revision: rev, filename: "css/style.css", err: ""},
+		{path: "/abc123/d3/d3.min.js", gistID: "abc123", filename: "d3/d3.min.js", err: ""},
+		{path: "/abc123/2020/index.html", gistID: "abc123", filename: "2020/index.html", err: ""},
+		{path: "/abc123/cafe/x.css", gistID: "abc123", filename: "cafe/x.css", err: ""},
+		{path: "/abc123/cafe/", gistID: "abc123", filename: "cafe/", err: ""},
+		{path: "/abc123/LICENSE", gistID: "abc123", filename: "LICENSE", err: ""},
+		{path: "/cafe/abc123/x.css", gistID: "abc123", filename: "x.css", err: ""},
+		{path: "/user100/def456/", gistID: "def456", filename: "", err: ""},
+		{path: "/def456/2020/index.html", gistID: "2020", filename: "index.html", err: ""},
 	}
+
+	// Only "abc123" is a known gist. Paths to unknown gists can start with a username.
+	exists := func(id string) bool { return id == "abc123" }
 	for i, tt := range tests {
-		gistID, filename, err := gist.ParsePath(tt.path)
+		gistID, revision, filename, err := gist.ParsePath(tt.path, exists)
 		var errstr string
 		if err != nil {
 			errstr = err.Error()
@@ -337,12 +1241,54 @@ func TestParsePath(t *testing.T) {
 			t.Errorf("%d. error: exp: %s, got: %s", i, tt.err, errstr)
 		} else if tt.gistID != gistID {
 			t.Errorf("%d. gistID: exp: %s, got: %s", i, tt.gistID, gistID)
+		} else if tt.revision != revision {
+			t.Errorf("%d. revision: exp: %s, got: %s", i, tt.revision, revision)
 		} else if tt.filename != filename {
 			t.Errorf("%d. filename: exp: %s, got: %s", i, tt.filename, filename)
 		}
 	}
 }
 
+// Ensure that script embed paths can be parsed.
+func TestParseScriptPath(t *testing.T) {
+	const rev = "57a7f021a713b1c5a6a199b54cc514735d2d462f"
+	var tests = []struct {
+		path     string
+		gistID   string
+		revision string
+		ok       bool
+	}{
+		{path: "/abc123.js", gistID: "abc123", ok: true},
+		{path: "/user100/abc123.js", gistID: "abc123", ok: true},
+		{path: "/abc123/" + rev + ".js", gistID: "abc123", revision: rev, ok: true},
+		{path: "/user100/abc123/" + rev + ".js", gistID: "abc123", revision: rev, ok: true},
+		{path: "/abc123", ok: false},
+		{path: "/abc123/app.js", ok: false},
+		{path: "/abc123/def456.js", ok: false},
+		{path: "/abc123/.js", ok: false},
+		{path: "/user100/abc123/app.js", ok: false},
+		{path: "/user100.js", ok: false},
+		{path: "/../abc123.js", ok: false},
+		{path: "/123456/abc123.js", gistID: "abc123", ok: true},
+		{path: "/cafe/abc123/" + rev + ".js", gistID: "abc123", revision: rev, ok: true},
+		{path: "/def456.js", ok: false},
+		{path: "/user100/def456.js", ok: false},
+	}
+
+	// Only "abc123" is a known gist.
+	exists := func(id string) bool { return id == "abc123" }
+	for i, tt := range tests {
+		gistID, revision, ok := gist.ParseScriptPath(tt.path, exists)
+		if tt.ok != ok {
+			t.Errorf("%d. ok: exp: %v, got: %v", i, tt.ok, ok)
+		} else if tt.gistID != gistID {
+			t.Errorf("%d. gistID: exp: %s, got: %s", i, tt.gistID, gistID)
+		} else if tt.revision != revision {
+			t.Errorf("%d. revision: exp: %s, got: %s", i, tt.revision, revision)
+		}
+	}
+}
+
 // TestHandler represents a handler used for testing.
 type TestHandler struct {
 	*gist.Handler
@@ -415,3 +1361,19 @@ func readall(r io.Reader) string {
 	b, _ := ioutil.ReadAll(r)
 	return string(b)
 }
+
+// MustParseDocumentWrites returns the strings written by a script embed.
+func MustParseDocumentWrites(script string) []string {
+	var a []string
+	for _, line := range strings.Split(strings.TrimSpace(script), "\n") {
+		if !strings.HasPrefix(line, "document.write(") || !strings.HasSuffix(line, ");") {
+			panic("invalid line: " + line)
+		}
+		var s string
+		if err := json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimPrefix(line, "document.write("), ");")), &s); err != nil {
+			panic(err)
+		}
+		a = append(a, s)
+	}
+	return a
+}
@@ -0,0 +1,144 @@
+package gist
+
+import (
+	"bytes"
+	"html"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma"
+	chromahtml "github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+)
+
+// CodeStyle is the highlighting style used by the code view.
+const CodeStyle = "github"
+
+// codeClassPrefix is added to the highlighting classes so they do not
+// collide with the styles of pages that gists are embedded in.
+const codeClassPrefix = "gist-exposed-"
+
+// maxHighlightSize is the largest file that is highlighted. Larger files
+// are listed as plain text.
+const maxHighlightSize = 1 << 20
+
+// codeStylesheet is the stylesheet for the code view. It is served at
+// "/_/code.css" and inlined in code view pages.
+var codeStylesheet = func() []byte {
+	var buf bytes.Buffer
+	_, _ = buf.Write(codeCSS())
+	f := chromahtml.New(chromahtml.WithClasses(true), chromahtml.ClassPrefix(codeClassPrefix))
+	_ = f.WriteCSS(&buf, styles.Get(CodeStyle))
+	return buf.Bytes()
+}()
+
+// codeFile represents a gist file rendered in the code view.
+type codeFile struct {
+	Filename string // name shown in the footer
+	URL      string // link to the raw file
+	Anchor   string // prefix of line anchor IDs
+	Content  []byte
+}
+
+// writeCodeView writes a highlighted listing of each file with line numbers.
+// The markup is styled by codeStylesheet.
+func writeCodeView(buf *bytes.Buffer, files []*codeFile) {
+	_, _ = buf.WriteString(`<div class="gist-exposed-embed">`)
+	for _, f := range files {
+		_, _ = buf.WriteString(`<div class="gist-exposed-file">`)
+		if isBinary(f.Content) {
+			_, _ = buf.WriteString(`<div class="gist-exposed-binary">Binary file not shown.</div>`)
+		} else {
+			writeCodeLines(buf, f)
+		}
+		_, _ = buf.WriteString(`<div class="gist-exposed-meta">`)
+		_, _ = buf.WriteString(`<a class="gist-exposed-raw" href="` + html.EscapeString(f.URL) + `">view raw</a>`)
+		_, _ = buf.WriteString(`<a href="` + html.EscapeString(f.URL) + `">` + html.EscapeString(f.Filename) + `</a>`)
+		_, _ = buf.WriteString(` hosted by <a href="https://gist.exposed">Gist Exposed!</a>`)
+		_, _ = buf.WriteString(`</div></div>`)
+	}
+	_, _ = buf.WriteString(`</div>`)
+}
+
+// writeCodeLines writes a table with a row for each line of the file. Each
+// row can be linked to by its anchor. Line numbers are added by the
+// stylesheet so they are not copied with the code.
+func writeCodeLines(buf *bytes.Buffer, f *codeFile) {
+	_, _ = buf.WriteString(`<table class="gist-exposed-code gist-exposed-chroma"><tbody>`)
+	for i, line := range highlightLines(f.Filename, f.Content) {
+		n := strconv.Itoa(i + 1)
+		id := html.EscapeString(f.Anchor + n)
+		_, _ = buf.WriteString(`<tr id="` + id + `"><td class="gist-exposed-num"><a href="#` + id + `" data-line-number="` + n + `"></a></td>`)
+		_, _ = buf.WriteString(`<td class="gist-exposed-line">`)
+		for _, token := range line {
+			s := html.EscapeString(strings.TrimRight(token.Value, "\r\n"))
+			if s == "" {
+				continue
+			} else if class := tokenClass(token.Type); class != "" {
+				_, _ = buf.WriteString(`<span class="` + class + `">` + s + `</span>`)
+			} else {
+				_, _ = buf.WriteString(s)
+			}
+		}
+		_, _ = buf.WriteString(`</td></tr>`)
+	}
+	_, _ = buf.WriteString(`</tbody></table>`)
+}
+
+// highlightLines splits a file into lines of highlighted tokens. The lexer
+// is chosen by the filename or, failing that, by analysing the content.
+// Files that cannot be highlighted are returned as plain text.
+func highlightLines(filename string, content []byte) [][]chroma.Token {
+	text := string(content)
+
+	lexer := lexers.Match(filename)
+	if lexer == nil && len(content) <= maxHighlightSize {
+		lexer = lexers.Analyse(text)
+	}
+	if lexer == nil || len(content) > maxHighlightSize {
+		lexer = lexers.Fallback
+	}
+
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, text)
+	if err != nil {
+		it = chroma.Literator(chroma.Token{Type: chroma.Text, Value: text})
+	}
+	return chroma.SplitTokensIntoLines(it.Tokens())
+}
+
+// tokenClass returns the CSS class for a token type. Types without a class
+// of their own use the class of their parent.
+func tokenClass(t chroma.TokenType) string {
+	for ; t != 0; t = t.Parent() {
+		if class, ok := chroma.StandardTypes[t]; ok {
+			if class == "" {
+				return ""
+			}
+			return codeClassPrefix + class
+		}
+	}
+	return ""
+}
+
+// anchorPrefix returns a prefix for the line anchors of a file that is
+// unique within a page containing several files, such as "file-main-go-L".
+func anchorPrefix(filename string) string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("file-")
+	for _, ch := range strings.ToLower(filename) {
+		if (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9') {
+			_, _ = buf.WriteRune(ch)
+		} else {
+			_ = buf.WriteByte('-')
+		}
+	}
+	_, _ = buf.WriteString("-L")
+	return buf.String()
+}
+
+// isBinary returns true if b does not appear to be text.
+func isBinary(b []byte) bool {
+	return bytes.IndexByte(b, 0) != -1 || !utf8.Valid(b)
+}
@@ -0,0 +1,149 @@
+package gist
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSyncInterval is the time between background syncs of hosted gists.
+	DefaultSyncInterval = 10 * time.Minute
+
+	// DefaultSyncConcurrency is the number of gists refreshed in parallel.
+	DefaultSyncConcurrency = 4
+)
+
+// Syncer periodically refreshes all hosted gists from GitHub using the
+// access token of each gist's owner.
+type Syncer struct {
+	db      *DB
+	closing chan struct{}
+	wg      sync.WaitGroup
+
+	// Interval is the time between syncs. A zero interval disables syncing.
+	Interval time.Duration
+
+	// Concurrency is the number of gists refreshed in parallel.
+	Concurrency int
+
+	Logger *log.Logger
+}
+
+// NewSyncer returns a new instance of Syncer.
+func NewSyncer(db *DB) *Syncer {
+	return &Syncer{
+		db:          db,
+		Interval:    DefaultSyncInterval,
+		Concurrency: DefaultSyncConcurrency,
+		Logger:      log.New(os.Stderr, "", log.LstdFlags),
+	}
+}
+
+// Open starts the background sync. Does nothing if the interval is zero.
+func (s *Syncer) Open() error {
+	if s.Interval <= 0 {
+		return nil
+	}
+
+	s.closing = make(chan struct{})
+	s.wg.Add(1)
+	go s.run()
+	return nil
+}
+
+// Close stops the background sync and waits for the current sync to finish.
+func (s *Syncer) Close() error {
+	if s.closing != nil {
+		close(s.closing)
+		s.wg.Wait()
+		s.closing = nil
+	}
+	return nil
+}
+
+// run executes a sync every interval until the syncer is closed.
+func (s *Syncer) run() {
+	defer s.wg.Done()
+	defer autonotify()
+
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-ticker.C:
+			if err := s.Sync(); err != nil {
+				s.Logger.Printf("sync: %s", err)
+			}
+		}
+	}
+}
+
+// Sync refreshes every hosted gist and records the result for each gist.
+// Errors refreshing individual gists are recorded but not returned.
+func (s *Syncer) Sync() error {
+	// Retrieve all hosted gists from the index.
+	var refs []gistRef
+	if err := s.db.View(func(tx *Tx) (err error) {
+		refs, err = tx.hostedGists()
+		return
+	}); err != nil {
+		return fmt.Errorf("hosted gists: %s", err)
+	}
+
+	// Send gists to a bounded pool of workers.
+	ch := make(chan gistRef)
+	var wg sync.WaitGroup
+	for i := 0; i < s.concurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer autonotify()
+			for ref := range ch {
+				s.syncGist(ref)
+			}
+		}()
+	}
+
+	for _, ref := range refs {
+		ch <- ref
+	}
+	close(ch)
+	wg.Wait()
+
+	return nil
+}
+
+// syncGist refreshes a single gist and records the sync status.
+func (s *Syncer) syncGist(ref gistRef) {
+	status := &SyncStatus{SyncedAt: time.Now().UTC()}
+	if err := s.db.LoadGist(ref.userID, ref.gistID); err != nil {
+		s.Logger.Printf("sync gist: %s: %s", ref.gistID, err)
+		status.Error = err.Error()
+	}
+
+	if err := s.db.Update(func(tx *Tx) error {
+		return tx.SaveSyncStatus(ref.gistID, status)
+	}); err != nil {
+		s.Logger.Printf("save sync status: %s: %s", ref.gistID, err)
+	}
+}
+
+// concurrency returns the number of workers to use.
+func (s *Syncer) concurrency() int {
+	if s.Concurrency < 1 {
+		return 1
+	}
+	return s.Concurrency
+}
+
+// gistRef identifies a hosted gist and its owner.
+type gistRef struct {
+	userID int
+	gistID string
+}
@@ -0,0 +1,88 @@
+package gist_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/benbjohnson/gist"
+)
+
+// Ensure that the syncer refreshes every hosted gist with its owner's token
+// and records the result of each sync.
+func TestSyncer_Sync(t *testing.T) {
+	// Run mock GitHub raw server.
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer s.Close()
+
+	db := NewTestDB()
+	defer db.Close()
+	db.MustSaveUser(&gist.User{ID: 1000, AccessToken: "AAA"})
+	db.MustSaveUser(&gist.User{ID: 2000, AccessToken: "BBB"})
+
+	// Host a gist for each user.
+	ok(t, db.Update(func(tx *gist.Tx) error {
+		ok(t, tx.SaveGist(&gist.Gist{ID: "xxx", UserID: 1000}))
+		ok(t, tx.SaveGist(&gist.Gist{ID: "yyy", UserID: 2000}))
+		return nil
+	}))
+
+	// Return a new version for the first user and fail for the second.
+	var mu sync.Mutex
+	tokens := make(map[string]string)
+	db.NewGitHubClient = func(token string) gist.GitHubClient {
+		client := &MockGitHubClient{}
+		client.GistFunc = func(id string) (*gist.Gist, error) {
+			mu.Lock()
+			tokens[id] = token
+			mu.Unlock()
+
+			if id == "yyy" {
+				return nil, errors.New("marker")
+			}
+			return &gist.Gist{ID: id, UserID: 1000, Description: "updated", Files: []*gist.GistFile{
+				{Filename: "index.html", RawURL: s.URL + "/index.html"},
+			}}, nil
+		}
+		return client
+	}
+
+	syncer := gist.NewSyncer(db.DB)
+	syncer.Logger = log.New(ioutil.Discard, "", 0)
+	ok(t, syncer.Sync())
+
+	// Each gist should be refreshed using its owner's token.
+	equals(t, map[string]string{"xxx": "AAA", "yyy": "BBB"}, tokens)
+
+	ok(t, db.View(func(tx *gist.Tx) error {
+		// The first gist should be updated.
+		g, _ := tx.Gist("xxx")
+		equals(t, "updated", g.Description)
+		status, _ := tx.SyncStatus("xxx")
+		assert(t, !status.SyncedAt.IsZero(), "expected sync time")
+		equals(t, "", status.Error)
+
+		// The second gist should record its error.
+		status, _ = tx.SyncStatus("yyy")
+		assert(t, !status.SyncedAt.IsZero(), "expected sync time")
+		equals(t, "gist: marker", status.Error)
+		return nil
+	}))
+}
+
+// Ensure that a syncer with a zero interval can be opened and closed.
+func TestSyncer_Open_Disabled(t *testing.T) {
+	db := NewTestDB()
+	defer db.Close()
+
+	syncer := gist.NewSyncer(db.DB)
+	syncer.Interval = 0
+	ok(t, syncer.Open())
+	ok(t, syncer.Close())
+}